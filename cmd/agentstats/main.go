@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -14,7 +15,7 @@ func main() {
 		Use:   "agentstats",
 		Short: "Track AI coding agent working time and prompt history",
 		Long: `agentstats records prompt timing, session data, and git state for AI coding
-agents. Use 'stats' and 'history' to inspect recorded data.`,
+agents. Use 'stats', 'history' and 'cost' to inspect recorded data.`,
 		SilenceUsage: true,
 	}
 
@@ -22,9 +23,24 @@ agents. Use 'stats' and 'history' to inspect recorded data.`,
 		hook.NewHookCmd(),
 		cli.NewStatsCmd(),
 		cli.NewHistoryCmd(),
+		cli.NewSessionsCmd(),
+		cli.NewShowCmd(),
+		cli.NewHotspotsCmd(),
+		cli.NewCommitsCmd(),
+		cli.NewCostCmd(),
+		cli.NewImportCmd(),
+		cli.NewRunCmd(),
+		cli.NewGCCmd(),
+		cli.NewGitHookCmd(),
+		cli.NewNotesCmd(),
+		cli.NewProjectsCmd(),
 	)
 
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *cli.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
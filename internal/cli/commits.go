@@ -0,0 +1,186 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// NewCommitsCmd returns the 'commits' subcommand.
+func NewCommitsCmd() *cobra.Command {
+	var projectDir string
+	var dbPath string
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "commits",
+		Short: "Show commits made during prompts with the agent time behind each",
+		Long: `Show commits made while a prompt was running, newest first. Agent time is the
+working time of the project's prompts between the commit's parent being made
+and the commit itself, i.e. roughly how much agent time went into the commit.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCommits(dbPath, projectDir, limit)
+		},
+	}
+
+	cmd.Flags().StringVarP(&projectDir, "project", "p", "", "Project directory (default: current directory)")
+	cmd.Flags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "Number of commits to show")
+	return cmd
+}
+
+type commitRow struct {
+	hash         string
+	promptID     string
+	committedAt  string
+	parentAt     string // "" for a root commit
+	subject      string
+	insertions   int
+	deletions    int
+	agentSeconds float64
+}
+
+func runCommits(dbPath, projectDir string, limit int) error {
+	if dbPath == "" {
+		dbPath = db.DefaultPath()
+	}
+	if projectDir == "" {
+		var err error
+		projectDir, err = os.Getwd()
+		if err != nil {
+			return fmt.Errorf("get cwd: %w", err)
+		}
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer database.Close()
+
+	proj, err := project.Find(database, projectDir)
+	if err != nil {
+		return fmt.Errorf("find project: %w", err)
+	}
+	if proj == nil {
+		fmt.Println("No project found for", projectDir)
+		fmt.Println("Run an AI agent in this directory first to start tracking.")
+		return nil
+	}
+
+	rows, err := queryCommits(database, proj.ID, limit)
+	if err != nil {
+		return fmt.Errorf("query commits: %w", err)
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No commits recorded during prompts yet.")
+		return nil
+	}
+
+	printCommits(rows)
+	return nil
+}
+
+// queryCommits returns the project's commits, newest first. A commit seen by
+// several prompts (e.g. overlapping sessions) is credited to the one running
+// when it was made, else the latest submitted before it.
+func queryCommits(database *sql.DB, projectID string, limit int) ([]commitRow, error) {
+	sqlRows, err := database.Query(`
+		SELECT
+			c.hash,
+			(SELECT oc.prompt_id
+			 FROM prompt_commits oc
+			 JOIN prompts op ON op.id = oc.prompt_id
+			 WHERE oc.hash = c.hash AND op.project_id = p.project_id
+			 ORDER BY
+			     op.submitted_at <= oc.committed_at AND oc.committed_at <= COALESCE(op.completed_at, oc.committed_at) DESC,
+			     op.submitted_at <= oc.committed_at DESC,
+			     op.submitted_at DESC
+			 LIMIT 1),
+			strftime('%Y-%m-%d %H:%M:%S', c.committed_at),
+			COALESCE(strftime('%Y-%m-%d %H:%M:%S', c.parent_committed_at), ''),
+			COALESCE(c.subject, ''),
+			c.insertions,
+			c.deletions
+		FROM prompt_commits c
+		JOIN prompts p ON p.id = c.prompt_id
+		WHERE p.project_id = ?
+		GROUP BY c.hash
+		ORDER BY c.committed_at DESC
+		LIMIT ?
+	`, projectID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []commitRow
+	for sqlRows.Next() {
+		var r commitRow
+		if err := sqlRows.Scan(&r.hash, &r.promptID, &r.committedAt, &r.parentAt, &r.subject, &r.insertions, &r.deletions); err != nil {
+			sqlRows.Close()
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	sqlRows.Close()
+	if err := sqlRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		r := &results[i]
+		prompts, err := promptsBetween(database, projectID, r.parentAt, r.committedAt, false)
+		if err != nil {
+			return nil, err
+		}
+		r.agentSeconds = totalSeconds(prompts)
+	}
+	return results, nil
+}
+
+func printCommits(rows []commitRow) {
+	// Column widths.
+	const (
+		hashW     = 8
+		timeW     = 19
+		durationW = 12
+		linesW    = 14
+		idW       = 8
+	)
+
+	header := fmt.Sprintf("%-*s  %-*s  %-*s  %-*s  %-*s  %s",
+		hashW, "Commit",
+		timeW, "Committed",
+		durationW, "Agent time",
+		linesW, "Lines",
+		idW, "Prompt",
+		"Subject",
+	)
+	sep := strings.Repeat("-", hashW) + "  " +
+		strings.Repeat("-", timeW) + "  " +
+		strings.Repeat("-", durationW) + "  " +
+		strings.Repeat("-", linesW) + "  " +
+		strings.Repeat("-", idW) + "  " +
+		strings.Repeat("-", 40)
+
+	fmt.Println(header)
+	fmt.Println(sep)
+
+	for _, r := range rows {
+		fmt.Printf("%-*s  %-*s  %-*s  %-*s  %-*s  %s\n",
+			hashW, truncateID(r.hash, hashW),
+			timeW, r.committedAt,
+			durationW, formatDuration(math.Round(r.agentSeconds)),
+			linesW, fmt.Sprintf("+%d -%d", r.insertions, r.deletions),
+			idW, truncateID(r.promptID, idW),
+			truncate(r.subject, 60),
+		)
+	}
+}
@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/dansimau/agentstats/internal/db"
+)
+
+func TestQueryCommits_CreditsRunningPrompt(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	// Two overlapping prompts that both saw each commit.
+	for _, stmt := range []string{
+		`INSERT INTO projects (id, directory) VALUES ('proj', '/src/proj')`,
+		`INSERT INTO sessions (id, project_id) VALUES ('sess', 'proj')`,
+		`INSERT INTO prompts (id, session_id, project_id, submitted_at, completed_at, status) VALUES
+		     ('z-earlier', 'sess', 'proj', '2026-01-01 10:00:00', '2026-01-01 10:30:00', 'completed'),
+		     ('a-later', 'sess', 'proj', '2026-01-01 10:20:00', '2026-01-01 10:40:00', 'completed')`,
+		`INSERT INTO prompt_commits (prompt_id, hash, committed_at, files_changed, insertions, deletions) VALUES
+		     ('z-earlier', 'during-earlier', '2026-01-01 10:15:00', 1, 1, 0),
+		     ('a-later', 'during-earlier', '2026-01-01 10:15:00', 1, 1, 0),
+		     ('z-earlier', 'during-later', '2026-01-01 10:35:00', 1, 1, 0),
+		     ('a-later', 'during-later', '2026-01-01 10:35:00', 1, 1, 0),
+		     ('z-earlier', 'after-both', '2026-01-01 10:50:00', 1, 1, 0),
+		     ('a-later', 'after-both', '2026-01-01 10:50:00', 1, 1, 0)`,
+	} {
+		if _, err := database.Exec(stmt); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	rows, err := queryCommits(database, "proj", 10)
+	if err != nil {
+		t.Fatalf("queryCommits: %v", err)
+	}
+	got := map[string]string{}
+	for _, r := range rows {
+		got[r.hash] = r.promptID
+	}
+	for hash, want := range map[string]string{
+		"during-earlier": "z-earlier",
+		"during-later":   "a-later",
+		"after-both":     "a-later",
+	} {
+		if got[hash] != want {
+			t.Errorf("%s: credited to %q, want %q", hash, got[hash], want)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dansimau/agentstats/internal/config"
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/pricing"
+	"github.com/dansimau/agentstats/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// NewCostCmd returns the 'cost' subcommand.
+func NewCostCmd() *cobra.Command {
+	var projectDir string
+	var dbPath string
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "cost",
+		Short: "Estimate model spend for a project from recorded token usage",
+		Long: `Estimate model spend for a project from recorded token usage.
+
+Prices are applied at query time, so changing the pricing table re-prices all
+history. Override or extend the built-in prices in the config file, e.g.:
+
+  {"pricing": {"claude-sonnet-4": {"input": 3, "output": 15, "cache_read": 0.3, "cache_write": 3.75}}}
+
+Prices are USD per million tokens, keyed by model name prefix.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runCost(dbPath, configPath, projectDir)
+		},
+	}
+
+	cmd.Flags().StringVarP(&projectDir, "project", "p", "", "Project directory (default: current directory)")
+	cmd.Flags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to config file (default: XDG config dir)")
+	return cmd
+}
+
+// modelUsage is the token usage of all prompts that ran on one model.
+type modelUsage struct {
+	model            string
+	prompts          int
+	inputTokens      int64
+	outputTokens     int64
+	cacheReadTokens  int64
+	cacheWriteTokens int64
+}
+
+// cost returns the estimated cost of u, and false if the model has no price.
+func (u modelUsage) cost(table pricing.Table) (float64, bool) {
+	p, ok := table.Lookup(u.model)
+	if !ok {
+		return 0, false
+	}
+	return p.Cost(u.inputTokens, u.outputTokens, u.cacheReadTokens, u.cacheWriteTokens), true
+}
+
+func runCost(dbPath, configPath, projectDir string) error {
+	if dbPath == "" {
+		dbPath = db.DefaultPath()
+	}
+	if configPath == "" {
+		configPath = config.DefaultPath()
+	}
+	if projectDir == "" {
+		var err error
+		projectDir, err = os.Getwd()
+		if err != nil {
+			return fmt.Errorf("get cwd: %w", err)
+		}
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer database.Close()
+
+	proj, err := project.Find(database, projectDir)
+	if err != nil {
+		return fmt.Errorf("find project: %w", err)
+	}
+	if proj == nil {
+		fmt.Println("No project found for", projectDir)
+		fmt.Println("Run an AI agent in this directory first to start tracking.")
+		return nil
+	}
+
+	usage, err := queryModelUsage(database, promptFilter{projectID: proj.ID})
+	if err != nil {
+		return fmt.Errorf("query usage: %w", err)
+	}
+
+	fmt.Printf("Project: %s", proj.ShortName())
+	if proj.DisplayOrigin() != "" {
+		fmt.Printf(" (%s)", proj.DisplayOrigin())
+	}
+	fmt.Println()
+	fmt.Println()
+
+	if len(usage) == 0 {
+		fmt.Println("No token usage recorded yet.")
+		return nil
+	}
+
+	printCost(usage, cfg.Pricing)
+	return nil
+}
+
+func queryModelUsage(database *sql.DB, filter promptFilter) ([]modelUsage, error) {
+	where, args := filter.where()
+	rows, err := database.Query(`
+		SELECT
+			model,
+			COUNT(*),
+			COALESCE(SUM(input_tokens), 0),
+			COALESCE(SUM(output_tokens), 0),
+			COALESCE(SUM(cache_read_tokens), 0),
+			COALESCE(SUM(cache_write_tokens), 0)
+		FROM prompts
+		WHERE `+where+` AND model IS NOT NULL
+		GROUP BY model
+		ORDER BY model
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []modelUsage
+	for rows.Next() {
+		var u modelUsage
+		if err := rows.Scan(
+			&u.model,
+			&u.prompts,
+			&u.inputTokens,
+			&u.outputTokens,
+			&u.cacheReadTokens,
+			&u.cacheWriteTokens,
+		); err != nil {
+			return nil, err
+		}
+		results = append(results, u)
+	}
+	return results, rows.Err()
+}
+
+// totalCost sums the estimated cost of usage. unpriced reports whether any
+// model had no price and was left out of the total.
+func totalCost(usage []modelUsage, table pricing.Table) (total float64, unpriced bool) {
+	for _, u := range usage {
+		c, ok := u.cost(table)
+		if !ok {
+			unpriced = true
+			continue
+		}
+		total += c
+	}
+	return total, unpriced
+}
+
+func printCost(usage []modelUsage, table pricing.Table) {
+	const (
+		modelW  = 30
+		numW    = 8
+		tokensW = 11
+		costW   = 10
+	)
+
+	fmt.Printf("%-*s  %*s  %*s  %*s  %*s  %*s  %*s\n",
+		modelW, "Model",
+		numW, "Prompts",
+		tokensW, "Input",
+		tokensW, "Output",
+		tokensW, "Cache read",
+		tokensW, "Cache write",
+		costW, "Cost",
+	)
+	fmt.Println(strings.Repeat("-", modelW) + "  " +
+		strings.Repeat("-", numW) + "  " +
+		strings.Repeat("-", tokensW) + "  " +
+		strings.Repeat("-", tokensW) + "  " +
+		strings.Repeat("-", tokensW) + "  " +
+		strings.Repeat("-", tokensW) + "  " +
+		strings.Repeat("-", costW))
+
+	for _, u := range usage {
+		cost := "?"
+		if c, ok := u.cost(table); ok {
+			cost = formatCost(c)
+		}
+		fmt.Printf("%-*s  %*d  %*s  %*s  %*s  %*s  %*s\n",
+			modelW, truncate(u.model, modelW),
+			numW, u.prompts,
+			tokensW, formatTokens(u.inputTokens),
+			tokensW, formatTokens(u.outputTokens),
+			tokensW, formatTokens(u.cacheReadTokens),
+			tokensW, formatTokens(u.cacheWriteTokens),
+			costW, cost,
+		)
+	}
+
+	total, unpriced := totalCost(usage, table)
+	fmt.Println()
+	fmt.Printf("Estimated total: %s\n", formatCost(total))
+	if unpriced {
+		fmt.Println("Models marked ? have no price configured and are excluded from the total.")
+	}
+}
+
+func formatCost(usd float64) string {
+	return fmt.Sprintf("$%.2f", usd)
+}
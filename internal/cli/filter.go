@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/timerange"
+)
+
+// promptFilter selects the prompts a report covers.
+type promptFilter struct {
+	projectID string // "" for all projects
+	branch    string // "" for all branches
+
+	// subdir restricts to prompts run in this directory (relative to the
+	// repo root) or below it; "" for the whole project.
+	subdir string
+
+	// since and until restrict to prompts submitted in [since, until); zero
+	// for no bound.
+	since time.Time
+	until time.Time
+}
+
+// where returns a condition on the prompts table selecting the filtered
+// prompts, for use in a WHERE clause, and its arguments.
+func (f promptFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if f.projectID != "" {
+		conds = append(conds, "prompts.project_id = ?")
+		args = append(args, f.projectID)
+	}
+	if f.branch != "" {
+		conds = append(conds, promptBranch+" = ?")
+		args = append(args, f.branch)
+	}
+	if f.subdir != "" {
+		conds = append(conds, "(prompts.subdir = ? OR substr(prompts.subdir, 1, ?) = ?)")
+		args = append(args, f.subdir, len(f.subdir)+1, f.subdir+"/")
+	}
+	if !f.since.IsZero() {
+		conds = append(conds, "prompts.submitted_at >= ?")
+		args = append(args, db.FormatTime(f.since))
+	}
+	if !f.until.IsZero() {
+		conds = append(conds, "prompts.submitted_at < ?")
+		args = append(args, db.FormatTime(f.until))
+	}
+	if len(conds) == 0 {
+		return "1", nil
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// setTimeRange sets the filter's bounds from --since and --until
+// expressions (see timerange.Parse); either may be "".
+func (f *promptFilter) setTimeRange(since, until string) error {
+	now := time.Now()
+	if since != "" {
+		r, err := timerange.Parse(since, now)
+		if err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+		f.since = r.Start
+	}
+	if until != "" {
+		r, err := timerange.Parse(until, now)
+		if err != nil {
+			return fmt.Errorf("--until: %w", err)
+		}
+		f.until = r.End
+	}
+	if !f.since.IsZero() && !f.until.IsZero() && !f.since.Before(f.until) {
+		return fmt.Errorf("--since %s is not before --until %s", since, until)
+	}
+	return nil
+}
+
+// timeRange describes the filter's time bounds for a report header, or
+// returns "" if it has none.
+func (f promptFilter) timeRange() string {
+	const layout = "2006-01-02 15:04"
+	switch {
+	case !f.since.IsZero() && !f.until.IsZero():
+		return f.since.Format(layout) + " to " + f.until.Format(layout)
+	case !f.since.IsZero():
+		return "since " + f.since.Format(layout)
+	case !f.until.IsZero():
+		return "until " + f.until.Format(layout)
+	}
+	return ""
+}
+
+// promptBranch is the branch a prompt is attributed to: the one it started
+// on, or for prompts recorded without a start snapshot, the one it ended on.
+const promptBranch = "COALESCE(prompts.branch_start, prompts.branch_end)"
+
+// groupings are the values accepted by --group-by, mapped to the SQL
+// expression over prompts that each groups on.
+var groupings = map[string]string{
+	"branch":     promptBranch,
+	"worktree":   "prompts.worktree",
+	"subproject": "prompts.subproject",
+}
+
+// groupingExpr returns the SQL expression for a --group-by value.
+func groupingExpr(name string) (string, error) {
+	expr, ok := groupings[name]
+	if !ok {
+		names := make([]string, 0, len(groupings))
+		for n := range groupings {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown --group-by %q (want one of: %s)", name, strings.Join(names, ", "))
+	}
+	return expr, nil
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/timerange"
+)
+
+// seedPrompts creates a database with one completed prompt submitted at each
+// of times, keyed by name.
+func seedPrompts(t *testing.T, times map[string]time.Time) *sql.DB {
+	t.Helper()
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	for _, stmt := range []string{
+		`INSERT INTO projects (id, directory) VALUES ('proj', '/src/proj')`,
+		`INSERT INTO sessions (id, project_id) VALUES ('sess', 'proj')`,
+	} {
+		if _, err := database.Exec(stmt); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	for name, at := range times {
+		if _, err := database.Exec(
+			`INSERT INTO prompts (id, session_id, project_id, prompt_text, submitted_at, completed_at, status)
+			 VALUES (?, 'sess', 'proj', ?, ?, ?, ?)`,
+			name, name, db.FormatTime(at), db.FormatTime(at.Add(time.Minute)), db.StatusCompleted,
+		); err != nil {
+			t.Fatalf("seed prompt %s: %v", name, err)
+		}
+	}
+	return database
+}
+
+// filteredPrompts returns the IDs of the prompts filter selects, sorted, as
+// seen by both queryStats and queryHistory.
+func filteredPrompts(t *testing.T, database *sql.DB, filter promptFilter) string {
+	t.Helper()
+	rows, err := queryHistory(database, filter, 100)
+	if err != nil {
+		t.Fatalf("queryHistory: %v", err)
+	}
+	var ids []string
+	for _, r := range rows {
+		ids = append(ids, r.id)
+	}
+	sort.Strings(ids)
+
+	stats, err := queryStats(database, filter)
+	if err != nil {
+		t.Fatalf("queryStats: %v", err)
+	}
+	if stats.totalPrompts != len(ids) {
+		t.Errorf("queryStats counted %d prompts, queryHistory listed %d", stats.totalPrompts, len(ids))
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestTimeRangeFilter(t *testing.T) {
+	// Away from UTC, so local days start at a different UTC date and time.
+	loc := time.FixedZone("UTC+10", 10*60*60)
+	local := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = local })
+
+	day := time.Date(2024, 2, 15, 0, 0, 0, 0, loc)
+	database := seedPrompts(t, map[string]time.Time{
+		"before": day.Add(-time.Second),
+		"start":  day,
+		"during": day.Add(23 * time.Hour),
+		"end":    day.Add(24 * time.Hour),
+	})
+
+	for _, tc := range []struct {
+		since, until string
+		want         string
+	}{
+		{"2024-02-15", "2024-02-15", "during,start"},
+		{"2024-02-15", "", "during,end,start"},
+		{"", "2024-02-15", "before,during,start"},
+		{"2024-02-15 23:00", "2024-02-16", "during,end"},
+	} {
+		var filter promptFilter
+		if err := filter.setTimeRange(tc.since, tc.until); err != nil {
+			t.Fatalf("setTimeRange(%q, %q): %v", tc.since, tc.until, err)
+		}
+		if got := filteredPrompts(t, database, filter); got != tc.want {
+			t.Errorf("--since %q --until %q: got %s, want %s", tc.since, tc.until, got, tc.want)
+		}
+	}
+}
+
+func TestTimeRangeFilter_Today(t *testing.T) {
+	loc := time.FixedZone("UTC-7", -7*60*60)
+	local := time.Local
+	time.Local = loc
+	t.Cleanup(func() { time.Local = local })
+
+	today, err := timerange.Parse("today", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	database := seedPrompts(t, map[string]time.Time{
+		"yesterday": today.Start.Add(-time.Second),
+		"midnight":  today.Start,
+		"tonight":   today.End.Add(-time.Second),
+		"tomorrow":  today.End,
+	})
+
+	var filter promptFilter
+	if err := filter.setTimeRange("today", "today"); err != nil {
+		t.Fatalf("setTimeRange: %v", err)
+	}
+	if got, want := filteredPrompts(t, database, filter), "midnight,tonight"; got != want {
+		t.Errorf("--since today --until today: got %s, want %s", got, want)
+	}
+}
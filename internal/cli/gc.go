@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dansimau/agentstats/internal/config"
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/hook"
+	"github.com/spf13/cobra"
+)
+
+// NewGCCmd returns the 'gc' subcommand.
+func NewGCCmd() *cobra.Command {
+	var dbPath string
+	var configPath string
+	var staleAfter time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Mark prompts stuck in flight as timed out",
+		Long: `Mark prompts that have been in flight for longer than a threshold as timed
+out. A prompt gets stuck when its end event is lost, e.g. because the agent
+crashed, and no later prompt in the same session closed it.
+
+The threshold defaults to gc.stale_after in the config file (6h if unset).
+Timed-out prompts are given an end time from their last recorded activity or
+the session transcript's last modification. The hooks run this automatically
+whenever a prompt starts.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGC(dbPath, configPath, staleAfter)
+		},
+	}
+
+	cmd.Flags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to config file (default: XDG config dir)")
+	cmd.Flags().DurationVar(&staleAfter, "stale-after", 0, "Time out prompts in flight for longer than this (default: from config)")
+	return cmd
+}
+
+func runGC(dbPath, configPath string, staleAfter time.Duration) error {
+	if dbPath == "" {
+		dbPath = db.DefaultPath()
+	}
+	if configPath == "" {
+		configPath = config.DefaultPath()
+	}
+
+	if staleAfter <= 0 {
+		cfg, err := config.Load(configPath)
+		if err != nil {
+			return err
+		}
+		staleAfter = time.Duration(cfg.GC.StaleAfter)
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer database.Close()
+
+	n, err := hook.ReapStale(database, staleAfter)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Timed out %d prompt(s) in flight for longer than %s.\n", n, staleAfter)
+	return nil
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/gitx"
+	"github.com/dansimau/agentstats/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// gitHookMarker identifies hook scripts written by 'git-hook install'.
+const gitHookMarker = "# Installed by agentstats"
+
+// NewGitHookCmd returns the 'git-hook' subcommand (and its children).
+func NewGitHookCmd() *cobra.Command {
+	var dbPath string
+
+	gitHookCmd := &cobra.Command{
+		Use:   "git-hook",
+		Short: "Add agent time trailers to commit messages",
+	}
+
+	var force bool
+	installCmd := &cobra.Command{
+		Use:   "install",
+		Short: "Install the prepare-commit-msg hook in the current repo",
+		Long: `Install a prepare-commit-msg hook in the current repo that adds AI-Time and
+AI-Prompts trailers to each commit message, summarising the agent work since
+the previous commit. An existing hook not written by agentstats is left alone
+unless --force is given.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGitHookInstall(dbPath, force)
+		},
+	}
+	installCmd.Flags().BoolVar(&force, "force", false, "Replace an existing prepare-commit-msg hook")
+
+	prepareCmd := &cobra.Command{
+		Use:   "prepare-commit-msg <msg-file> [source [sha]]",
+		Short: "Append agent time trailers to a commit message (called by git)",
+		Args:  cobra.RangeArgs(1, 3),
+		// Like the agent hooks, this must never stop the commit.
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			// git passes "commit HEAD" when amending.
+			amend := len(args) == 3 && args[1] == "commit" && args[2] == "HEAD"
+			if err := runPrepareCommitMsg(dbPath, args[0], amend); err != nil {
+				fmt.Fprintln(os.Stderr, "agentstats git-hook error:", err)
+			}
+		},
+	}
+
+	gitHookCmd.PersistentFlags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	gitHookCmd.AddCommand(installCmd, prepareCmd)
+	return gitHookCmd
+}
+
+func runGitHookInstall(dbPath string, force bool) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get cwd: %w", err)
+	}
+	hooksDir := gitx.GitPath(cwd, "hooks")
+	if hooksDir == "" {
+		return fmt.Errorf("%s is not in a git repository", cwd)
+	}
+	hookPath := filepath.Join(hooksDir, "prepare-commit-msg")
+
+	if existing, err := os.ReadFile(hookPath); err == nil && !strings.Contains(string(existing), gitHookMarker) && !force {
+		return fmt.Errorf("%s already exists; use --force to replace it", hookPath)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("find agentstats executable: %w", err)
+	}
+	command := shellQuote(exe) + " git-hook prepare-commit-msg"
+	if dbPath != "" {
+		command += " --db " + shellQuote(dbPath)
+	}
+	script := "#!/bin/sh\n" +
+		gitHookMarker + ": adds AI-Time/AI-Prompts trailers.\n" +
+		command + ` "$@" || true` + "\n"
+
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(hookPath, []byte(script), 0o755); err != nil {
+		return fmt.Errorf("write hook: %w", err)
+	}
+	fmt.Println("Installed", hookPath)
+	return nil
+}
+
+func runPrepareCommitMsg(dbPath, msgFile string, amend bool) error {
+	if dbPath == "" {
+		dbPath = db.DefaultPath()
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get cwd: %w", err)
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer database.Close()
+
+	proj, err := project.Find(database, cwd)
+	if err != nil {
+		return fmt.Errorf("find project: %w", err)
+	}
+	if proj == nil {
+		return nil
+	}
+
+	// Work since the previous commit; the first commit gets everything. An
+	// amended commit replaces HEAD, so its previous commit is HEAD's parent.
+	prev := "HEAD"
+	if amend {
+		prev = "HEAD^"
+	}
+	var after string
+	if t, err := gitx.CommitTime(cwd, prev); err == nil {
+		after = db.FormatTime(t)
+	}
+	// The commit is usually made by a prompt still running, so count it.
+	prompts, err := promptsBetween(database, proj.ID, after, db.FormatTime(time.Now()), true)
+	if err != nil {
+		return fmt.Errorf("query prompts: %w", err)
+	}
+	if len(prompts) == 0 {
+		return nil
+	}
+
+	aiTime := strings.ReplaceAll(formatDuration(totalSeconds(prompts)), " ", "")
+	// --if-exists replace keeps amended commits from collecting duplicates.
+	cmd := exec.Command("git", "interpret-trailers", "--in-place",
+		"--if-exists", "replace",
+		"--trailer", "AI-Time: "+aiTime,
+		"--trailer", fmt.Sprintf("AI-Prompts: %d", len(prompts)),
+		msgFile,
+	)
+	cmd.Dir = cwd
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git interpret-trailers: %w: %s", err, out)
+	}
+	return nil
+}
+
+// shellQuote quotes s for use as a single word in a POSIX shell script.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
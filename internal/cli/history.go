@@ -16,32 +16,42 @@ func NewHistoryCmd() *cobra.Command {
 	var projectDir string
 	var dbPath string
 	var limit int
+	var branch string
+	var since, until string
 
 	cmd := &cobra.Command{
 		Use:   "history",
 		Short: "Show recent prompt history for a project",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runHistory(dbPath, projectDir, limit)
+			return runHistory(dbPath, projectDir, branch, since, until, limit)
 		},
 	}
 
-	cmd.Flags().StringVarP(&projectDir, "project", "p", "", "Project directory (default: current directory)")
+	cmd.Flags().StringVarP(&projectDir, "project", "p", "", "Project directory, or a directory below its root to show just that subtree (default: current directory)")
 	cmd.Flags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
 	cmd.Flags().IntVarP(&limit, "limit", "n", 50, "Number of prompts to show")
+	cmd.Flags().StringVarP(&branch, "branch", "b", "", "Only show prompts started on this branch")
+	cmd.Flags().StringVar(&since, "since", "", "Only show prompts submitted from this time, e.g. 2024-02-15, 7d, today, last-week")
+	cmd.Flags().StringVar(&until, "until", "", "Only show prompts submitted before the end of this time, e.g. 2024-02-15, yesterday")
 	return cmd
 }
 
 type promptRow struct {
 	num         int
+	id          string
 	submittedAt string
 	duration    string // "-" for in-flight
+	status      string
+	branch      string
 	promptText  string
 }
 
-func runHistory(dbPath, projectDir string, limit int) error {
+func runHistory(dbPath, projectDir, branch, since, until string, limit int) error {
 	if dbPath == "" {
 		dbPath = db.DefaultPath()
 	}
+	// An explicit --project below the project root selects that subtree.
+	subtree := projectDir != ""
 	if projectDir == "" {
 		var err error
 		projectDir, err = os.Getwd()
@@ -50,6 +60,11 @@ func runHistory(dbPath, projectDir string, limit int) error {
 		}
 	}
 
+	filter := promptFilter{branch: branch}
+	if err := filter.setTimeRange(since, until); err != nil {
+		return err
+	}
+
 	database, err := db.Open(dbPath)
 	if err != nil {
 		return fmt.Errorf("open db: %w", err)
@@ -66,12 +81,21 @@ func runHistory(dbPath, projectDir string, limit int) error {
 		return nil
 	}
 
-	rows, err := queryHistory(database, proj.ID, limit)
+	filter.projectID = proj.ID
+	if subtree {
+		filter.subdir = project.Subdir(projectDir)
+	}
+
+	rows, err := queryHistory(database, filter, limit)
 	if err != nil {
 		return fmt.Errorf("query history: %w", err)
 	}
 
 	if len(rows) == 0 {
+		if r := filter.timeRange(); r != "" {
+			fmt.Println("No prompts recorded in range:", r)
+			return nil
+		}
 		fmt.Println("No prompts recorded yet.")
 		return nil
 	}
@@ -80,21 +104,25 @@ func runHistory(dbPath, projectDir string, limit int) error {
 	return nil
 }
 
-func queryHistory(database *sql.DB, projectID string, limit int) ([]promptRow, error) {
+func queryHistory(database *sql.DB, filter promptFilter, limit int) ([]promptRow, error) {
+	where, args := filter.where()
 	sqlRows, err := database.Query(`
 		SELECT
 			ROW_NUMBER() OVER (ORDER BY submitted_at DESC) AS num,
+			id,
 			strftime('%Y-%m-%d %H:%M:%S', submitted_at) AS submitted_at,
 			CASE
 				WHEN completed_at IS NULL THEN NULL
 				ELSE CAST(ROUND((julianday(completed_at) - julianday(submitted_at)) * 86400) AS INTEGER)
 			END AS duration_secs,
+			status,
+			COALESCE(`+promptBranch+`, ''),
 			COALESCE(prompt_text, '')
 		FROM prompts
-		WHERE project_id = ?
+		WHERE `+where+`
 		ORDER BY submitted_at DESC
 		LIMIT ?
-	`, projectID, limit)
+	`, append(args, limit)...)
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +133,7 @@ func queryHistory(database *sql.DB, projectID string, limit int) ([]promptRow, e
 		var r promptRow
 		var durationSecs sql.NullInt64
 		var promptText string
-		if err := sqlRows.Scan(&r.num, &r.submittedAt, &durationSecs, &promptText); err != nil {
+		if err := sqlRows.Scan(&r.num, &r.id, &r.submittedAt, &durationSecs, &r.status, &r.branch, &promptText); err != nil {
 			return nil, err
 		}
 		if durationSecs.Valid {
@@ -123,34 +151,54 @@ func printHistory(rows []promptRow) {
 	// Column widths.
 	const (
 		numW      = 5
+		idW       = 8
 		timeW     = 19
 		durationW = 10
+		statusW   = 11
+		branchW   = 16
 	)
 
-	header := fmt.Sprintf("%-*s  %-*s  %-*s  %s",
+	header := fmt.Sprintf("%-*s  %-*s  %-*s  %-*s  %-*s  %-*s  %s",
 		numW, "#",
+		idW, "ID",
 		timeW, "Time",
 		durationW, "Duration",
+		statusW, "Status",
+		branchW, "Branch",
 		"Prompt",
 	)
 	sep := strings.Repeat("-", numW) + "  " +
+		strings.Repeat("-", idW) + "  " +
 		strings.Repeat("-", timeW) + "  " +
 		strings.Repeat("-", durationW) + "  " +
+		strings.Repeat("-", statusW) + "  " +
+		strings.Repeat("-", branchW) + "  " +
 		strings.Repeat("-", 47)
 
 	fmt.Println(header)
 	fmt.Println(sep)
 
 	for _, r := range rows {
-		fmt.Printf("%-*d  %-*s  %-*s  %s\n",
+		fmt.Printf("%-*d  %-*s  %-*s  %-*s  %-*s  %-*s  %s\n",
 			numW, r.num,
+			idW, truncateID(r.id, idW),
 			timeW, r.submittedAt,
 			durationW, r.duration,
+			statusW, r.status,
+			branchW, truncate(r.branch, branchW),
 			r.promptText,
 		)
 	}
 }
 
+// truncateID shortens an ID to a prefix that 'show' accepts.
+func truncateID(id string, n int) string {
+	if len(id) <= n {
+		return id
+	}
+	return id[:n]
+}
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
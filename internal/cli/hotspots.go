@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// NewHotspotsCmd returns the 'hotspots' subcommand.
+func NewHotspotsCmd() *cobra.Command {
+	var projectDir string
+	var dbPath string
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "hotspots",
+		Short: "Show the files the agent modifies most often in a project",
+		Long: `Show the files the agent modifies most often in a project, with the number of
+prompts that changed each file and the cumulative agent time of those prompts.
+Files that keep coming up are often ones the agent struggles with.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHotspots(dbPath, projectDir, limit)
+		},
+	}
+
+	cmd.Flags().StringVarP(&projectDir, "project", "p", "", "Project directory (default: current directory)")
+	cmd.Flags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "Number of files to show")
+	return cmd
+}
+
+type hotspotRow struct {
+	path         string
+	prompts      int
+	seconds      float64
+	linesAdded   int64
+	linesRemoved int64
+}
+
+func runHotspots(dbPath, projectDir string, limit int) error {
+	if dbPath == "" {
+		dbPath = db.DefaultPath()
+	}
+	if projectDir == "" {
+		var err error
+		projectDir, err = os.Getwd()
+		if err != nil {
+			return fmt.Errorf("get cwd: %w", err)
+		}
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer database.Close()
+
+	proj, err := project.Find(database, projectDir)
+	if err != nil {
+		return fmt.Errorf("find project: %w", err)
+	}
+	if proj == nil {
+		fmt.Println("No project found for", projectDir)
+		fmt.Println("Run an AI agent in this directory first to start tracking.")
+		return nil
+	}
+
+	rows, err := queryHotspots(database, proj.ID, limit)
+	if err != nil {
+		return fmt.Errorf("query hotspots: %w", err)
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No file changes recorded yet.")
+		return nil
+	}
+
+	printHotspots(rows)
+	return nil
+}
+
+func queryHotspots(database *sql.DB, projectID string, limit int) ([]hotspotRow, error) {
+	sqlRows, err := database.Query(`
+		SELECT
+			f.path,
+			COUNT(*),
+			COALESCE(SUM(
+				CASE WHEN p.completed_at IS NOT NULL
+				THEN (julianday(p.completed_at) - julianday(p.submitted_at)) * 86400.0
+				ELSE 0 END
+			), 0),
+			SUM(f.lines_added),
+			SUM(f.lines_removed)
+		FROM prompt_files f
+		JOIN prompts p ON p.id = f.prompt_id
+		WHERE p.project_id = ?
+		GROUP BY f.path
+		ORDER BY COUNT(*) DESC, 3 DESC, f.path
+		LIMIT ?
+	`, projectID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlRows.Close()
+
+	var results []hotspotRow
+	for sqlRows.Next() {
+		var r hotspotRow
+		if err := sqlRows.Scan(&r.path, &r.prompts, &r.seconds, &r.linesAdded, &r.linesRemoved); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, sqlRows.Err()
+}
+
+func printHotspots(rows []hotspotRow) {
+	// Column widths.
+	const (
+		promptsW  = 7
+		durationW = 12
+		linesW    = 14
+	)
+
+	header := fmt.Sprintf("%-*s  %-*s  %-*s  %s",
+		promptsW, "Prompts",
+		durationW, "Agent time",
+		linesW, "Lines",
+		"File",
+	)
+	sep := strings.Repeat("-", promptsW) + "  " +
+		strings.Repeat("-", durationW) + "  " +
+		strings.Repeat("-", linesW) + "  " +
+		strings.Repeat("-", 40)
+
+	fmt.Println(header)
+	fmt.Println(sep)
+
+	for _, r := range rows {
+		fmt.Printf("%-*d  %-*s  %-*s  %s\n",
+			promptsW, r.prompts,
+			durationW, formatDuration(r.seconds),
+			linesW, fmt.Sprintf("+%d -%d", r.linesAdded, r.linesRemoved),
+			r.path,
+		)
+	}
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/importer"
+	"github.com/spf13/cobra"
+)
+
+// NewImportCmd returns the 'import' subcommand (and its children).
+func NewImportCmd() *cobra.Command {
+	var dbPath string
+
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Backfill history from agent transcripts",
+	}
+
+	var claudeDir string
+	claudeCmd := &cobra.Command{
+		Use:   "claude",
+		Short: "Import sessions and prompts from Claude Code transcripts",
+		Long: `Import sessions and prompts from Claude Code transcripts.
+
+Safe to re-run: prompts already in the database, whether from an earlier
+import or recorded live by the hooks, are skipped.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImportClaude(dbPath, claudeDir)
+		},
+	}
+	claudeCmd.Flags().StringVar(&claudeDir, "dir", "", "Claude Code projects directory (default: ~/.claude/projects)")
+
+	importCmd.PersistentFlags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	importCmd.AddCommand(claudeCmd)
+	return importCmd
+}
+
+func runImportClaude(dbPath, dir string) error {
+	if dbPath == "" {
+		dbPath = db.DefaultPath()
+	}
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("get home dir: %w", err)
+		}
+		dir = filepath.Join(home, ".claude", "projects")
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer database.Close()
+
+	res, err := importer.ImportClaude(database, dir)
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	for _, err := range res.Unreadable {
+		fmt.Fprintln(os.Stderr, "agentstats: skipping unreadable transcript:", err)
+	}
+	fmt.Printf("Read %d transcripts: imported %d prompts in %d new sessions, skipped %d already recorded.\n",
+		res.Transcripts, res.Prompts, res.Sessions, res.Skipped)
+	if n := len(res.Unreadable); n > 0 {
+		fmt.Printf("Skipped %d unreadable transcripts.\n", n)
+	}
+	return nil
+}
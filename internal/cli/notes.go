@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/gitx"
+	"github.com/dansimau/agentstats/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// notesRef is the notes ref agentstats writes to, i.e. refs/notes/agentstats.
+const notesRef = "agentstats"
+
+// NewNotesCmd returns the 'notes' subcommand (and its children).
+func NewNotesCmd() *cobra.Command {
+	var projectDir string
+	var dbPath string
+
+	notesCmd := &cobra.Command{
+		Use:   "notes",
+		Short: "Share per-commit agent time via git notes",
+		Long: `Share per-commit agent time via git notes in refs/notes/agentstats. Notes
+travel with the repo, so teammates can see AI involvement without the database:
+
+  git push origin refs/notes/agentstats
+  git fetch origin refs/notes/agentstats:refs/notes/agentstats`,
+	}
+
+	var force bool
+	syncCmd := &cobra.Command{
+		Use:   "sync [<rev>]",
+		Short: "Write a note on each commit with the agent work that led to it",
+		Long: `Write a note on each commit reachable from <rev> (default HEAD) listing the
+prompts that ran between the commit's parent and the commit, with their
+durations and agent types. Commits without agent work get no note. Commits
+that already have a note are skipped unless --force is given.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rev := "HEAD"
+			if len(args) > 0 {
+				rev = args[0]
+			}
+			return runNotesSync(dbPath, projectDir, rev, force)
+		},
+	}
+	syncCmd.Flags().BoolVar(&force, "force", false, "Rewrite existing notes")
+	syncCmd.Flags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+
+	readCmd := &cobra.Command{
+		Use:   "read <rev>",
+		Short: "Show the agentstats note on a commit",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runNotesRead(projectDir, args[0])
+		},
+	}
+
+	notesCmd.PersistentFlags().StringVarP(&projectDir, "project", "p", "", "Project directory (default: current directory)")
+	notesCmd.AddCommand(syncCmd, readCmd)
+	return notesCmd
+}
+
+func runNotesSync(dbPath, projectDir, rev string, force bool) error {
+	if dbPath == "" {
+		dbPath = db.DefaultPath()
+	}
+	if projectDir == "" {
+		var err error
+		projectDir, err = os.Getwd()
+		if err != nil {
+			return fmt.Errorf("get cwd: %w", err)
+		}
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer database.Close()
+
+	proj, err := project.Find(database, projectDir)
+	if err != nil {
+		return fmt.Errorf("find project: %w", err)
+	}
+	if proj == nil {
+		fmt.Println("No project found for", projectDir)
+		fmt.Println("Run an AI agent in this directory first to start tracking.")
+		return nil
+	}
+
+	// Commits from before the first prompt can't have any agent work.
+	var first sql.NullString
+	if err := database.QueryRow(
+		`SELECT strftime('%Y-%m-%d %H:%M:%S', MIN(submitted_at)) FROM prompts WHERE project_id = ?`, proj.ID,
+	).Scan(&first); err != nil {
+		return fmt.Errorf("query prompts: %w", err)
+	}
+	if !first.Valid {
+		fmt.Println("No prompts recorded yet.")
+		return nil
+	}
+	since, err := db.ParseTime(first.String)
+	if err != nil {
+		return err
+	}
+
+	commits, err := gitx.CommitsSince(projectDir, rev, since)
+	if err != nil {
+		return err
+	}
+	noted, err := gitx.NotedCommits(projectDir, notesRef)
+	if err != nil {
+		return err
+	}
+
+	var written, skipped int
+	for _, c := range commits {
+		if noted[c.Hash] && !force {
+			skipped++
+			continue
+		}
+		var after string
+		if !c.ParentCommittedAt.IsZero() {
+			after = db.FormatTime(c.ParentCommittedAt)
+		}
+		prompts, err := promptsBetween(database, proj.ID, after, db.FormatTime(c.CommittedAt), false)
+		if err != nil {
+			return fmt.Errorf("query prompts: %w", err)
+		}
+		if len(prompts) == 0 {
+			continue
+		}
+		if err := gitx.WriteNote(projectDir, notesRef, c.Hash, formatNote(prompts)); err != nil {
+			return err
+		}
+		written++
+	}
+
+	fmt.Printf("Wrote %d note(s)", written)
+	if skipped > 0 {
+		fmt.Printf(", skipped %d commit(s) already noted", skipped)
+	}
+	fmt.Println(".")
+	return nil
+}
+
+// formatNote renders the note for a commit: trailer-style totals followed by
+// one line per prompt.
+func formatNote(prompts []workedPrompt) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "AI-Time: %s\n", strings.ReplaceAll(formatDuration(math.Round(totalSeconds(prompts))), " ", ""))
+	fmt.Fprintf(&b, "AI-Prompts: %d\n\n", len(prompts))
+	for _, p := range prompts {
+		fmt.Fprintf(&b, "%s  %s  %-12s  %-10s  %s\n",
+			truncateID(p.id, 8),
+			p.submittedAt,
+			truncate(p.agentType, 12),
+			formatDuration(math.Round(p.seconds)),
+			truncate(strings.Join(strings.Fields(p.promptText), " "), 60),
+		)
+	}
+	return b.String()
+}
+
+func runNotesRead(projectDir, rev string) error {
+	if projectDir == "" {
+		var err error
+		projectDir, err = os.Getwd()
+		if err != nil {
+			return fmt.Errorf("get cwd: %w", err)
+		}
+	}
+
+	note, err := gitx.ReadNote(projectDir, notesRef, rev)
+	if err != nil {
+		return err
+	}
+	if note == "" {
+		fmt.Printf("No agentstats note on %s.\n", rev)
+		return nil
+	}
+	fmt.Println(note)
+	return nil
+}
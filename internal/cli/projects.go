@@ -0,0 +1,317 @@
+package cli
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// NewProjectsCmd returns the 'projects' subcommand (and its children).
+func NewProjectsCmd() *cobra.Command {
+	var dbPath string
+
+	projectsCmd := &cobra.Command{
+		Use:   "projects",
+		Short: "List and manage tracked projects",
+		Long: `List and manage tracked projects. Commands that take a <project> accept its
+ID or a unique prefix of it, its name, its directory, or its git origin.`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List tracked projects with their prompts and working time",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProjectsList(dbPath)
+		},
+	}
+
+	renameCmd := &cobra.Command{
+		Use:   "rename <project> <name>",
+		Short: "Set the name a project is shown under",
+		Long: `Set the name a project is shown under, in place of its directory's base
+name. An empty name ("") reverts to the directory name.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProjectsRename(dbPath, args[0], args[1])
+		},
+	}
+
+	mergeCmd := &cobra.Command{
+		Use:   "merge <project> <duplicate>...",
+		Short: "Fold duplicate projects into one",
+		Long: `Move every session and prompt of each <duplicate> into <project> and delete
+the duplicates. <project> keeps its name, directory and git origin.
+
+A duplicate with no git origin is matched by directory, so prompts run in its
+directory later will start a new project; give the directories a common
+origin to keep them together.`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProjectsMerge(dbPath, args[0], args[1:])
+		},
+	}
+
+	var yes bool
+	forgetCmd := &cobra.Command{
+		Use:   "forget <project>",
+		Short: "Delete a project and all its sessions and prompts",
+		Long: `Delete a project and all its sessions and prompts, including their tool
+calls, waits, subagents, changed files and commits. This cannot be undone.
+Asks for confirmation unless --yes is given.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runProjectsForget(dbPath, args[0], yes)
+		},
+	}
+	forgetCmd.Flags().BoolVarP(&yes, "yes", "y", false, "Don't ask for confirmation")
+
+	projectsCmd.PersistentFlags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	projectsCmd.AddCommand(listCmd, renameCmd, mergeCmd, forgetCmd)
+	return projectsCmd
+}
+
+// openProjectsDB opens the database for the projects subcommands.
+func openProjectsDB(dbPath string) (*sql.DB, error) {
+	if dbPath == "" {
+		dbPath = db.DefaultPath()
+	}
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open db: %w", err)
+	}
+	return database, nil
+}
+
+// lookupProject resolves ref with project.Lookup, failing if nothing matches.
+func lookupProject(database *sql.DB, ref string) (*project.Project, error) {
+	proj, err := project.Lookup(database, ref)
+	if err != nil {
+		return nil, err
+	}
+	if proj == nil {
+		return nil, fmt.Errorf("no project matches %q; see 'agentstats projects list'", ref)
+	}
+	return proj, nil
+}
+
+type projectRow struct {
+	proj       *project.Project
+	prompts    int
+	seconds    float64
+	lastActive string // "" if the project has no prompts
+}
+
+func runProjectsList(dbPath string) error {
+	database, err := openProjectsDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	rows, err := queryProjects(database)
+	if err != nil {
+		return fmt.Errorf("query projects: %w", err)
+	}
+	if len(rows) == 0 {
+		fmt.Println("No projects tracked yet.")
+		return nil
+	}
+
+	printProjects(rows)
+	return nil
+}
+
+func queryProjects(database *sql.DB) ([]projectRow, error) {
+	projects, err := project.List(database)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlRows, err := database.Query(`
+		SELECT
+			project_id,
+			COUNT(*),
+			COALESCE(SUM(` + promptSeconds + `), 0),
+			strftime('%Y-%m-%d %H:%M:%S', MAX(COALESCE(completed_at, submitted_at)))
+		FROM prompts
+		GROUP BY project_id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlRows.Close()
+
+	totals := map[string]projectRow{}
+	for sqlRows.Next() {
+		var id string
+		var r projectRow
+		var lastActive sql.NullString
+		if err := sqlRows.Scan(&id, &r.prompts, &r.seconds, &lastActive); err != nil {
+			return nil, err
+		}
+		r.lastActive = lastActive.String
+		totals[id] = r
+	}
+	if err := sqlRows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows := make([]projectRow, 0, len(projects))
+	for _, p := range projects {
+		r := totals[p.ID]
+		r.proj = p
+		rows = append(rows, r)
+	}
+	return rows, nil
+}
+
+func printProjects(rows []projectRow) {
+	const (
+		idW       = 8
+		promptsW  = 7
+		durationW = 12
+		maxW      = 40
+	)
+	// Size the name, origin and directory columns to fit, within reason.
+	nameW, originW, dirW := len("Name"), len("Origin"), len("Directory")
+	for _, r := range rows {
+		nameW = max(nameW, min(len(r.proj.ShortName()), maxW))
+		originW = max(originW, min(len(r.proj.DisplayOrigin()), maxW))
+		dirW = max(dirW, min(len(r.proj.Directory), maxW))
+	}
+
+	fmt.Printf("%-*s  %-*s  %-*s  %-*s  %*s  %-*s  %s\n",
+		idW, "ID",
+		nameW, "Name",
+		originW, "Origin",
+		dirW, "Directory",
+		promptsW, "Prompts",
+		durationW, "Working time",
+		"Last active",
+	)
+	fmt.Println(strings.Repeat("-", idW) + "  " +
+		strings.Repeat("-", nameW) + "  " +
+		strings.Repeat("-", originW) + "  " +
+		strings.Repeat("-", dirW) + "  " +
+		strings.Repeat("-", promptsW) + "  " +
+		strings.Repeat("-", durationW) + "  " +
+		strings.Repeat("-", 19))
+
+	for _, r := range rows {
+		origin := r.proj.DisplayOrigin()
+		if origin == "" {
+			origin = "-"
+		}
+		lastActive := r.lastActive
+		if lastActive == "" {
+			lastActive = "-"
+		}
+		fmt.Printf("%-*s  %-*s  %-*s  %-*s  %*d  %-*s  %s\n",
+			idW, truncateID(r.proj.ID, idW),
+			nameW, truncate(r.proj.ShortName(), nameW),
+			originW, truncate(origin, originW),
+			dirW, truncate(r.proj.Directory, dirW),
+			promptsW, r.prompts,
+			durationW, formatDuration(r.seconds),
+			lastActive,
+		)
+	}
+}
+
+func runProjectsRename(dbPath, ref, name string) error {
+	database, err := openProjectsDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	proj, err := lookupProject(database, ref)
+	if err != nil {
+		return err
+	}
+	old := proj.ShortName()
+	if err := project.Rename(database, proj.ID, name); err != nil {
+		return err
+	}
+	proj.Name = name
+	fmt.Printf("Renamed %s to %s.\n", old, proj.ShortName())
+	return nil
+}
+
+func runProjectsMerge(dbPath, intoRef string, fromRefs []string) error {
+	database, err := openProjectsDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	into, err := lookupProject(database, intoRef)
+	if err != nil {
+		return err
+	}
+	// Resolve every ref before changing anything.
+	var from []*project.Project
+	for _, ref := range fromRefs {
+		p, err := lookupProject(database, ref)
+		if err != nil {
+			return err
+		}
+		if p.ID == into.ID {
+			return fmt.Errorf("%q is the project being merged into", ref)
+		}
+		from = append(from, p)
+	}
+
+	for _, p := range from {
+		if err := project.Merge(database, into.ID, p.ID); err != nil {
+			return err
+		}
+		fmt.Printf("Merged %s (%s) into %s.\n", p.ShortName(), p.Directory, into.ShortName())
+	}
+	return nil
+}
+
+func runProjectsForget(dbPath, ref string, yes bool) error {
+	database, err := openProjectsDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	proj, err := lookupProject(database, ref)
+	if err != nil {
+		return err
+	}
+
+	if !yes {
+		var prompts, sessions int
+		if err := database.QueryRow(
+			`SELECT (SELECT COUNT(*) FROM prompts WHERE project_id = ?1),
+			        (SELECT COUNT(*) FROM sessions WHERE project_id = ?1)`,
+			proj.ID,
+		).Scan(&prompts, &sessions); err != nil {
+			return fmt.Errorf("count prompts: %w", err)
+		}
+		fmt.Printf("Forget %s (%s) with %d prompt(s) in %d session(s)? [y/N] ",
+			proj.ShortName(), proj.Directory, prompts, sessions)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			fmt.Println("Aborted.")
+			return nil
+		}
+	}
+
+	if err := project.Forget(database, proj.ID); err != nil {
+		return err
+	}
+	fmt.Printf("Forgot %s.\n", proj.ShortName())
+	return nil
+}
@@ -0,0 +1,184 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/dansimau/agentstats/internal/config"
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/hook"
+	"github.com/dansimau/agentstats/internal/project"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// ExitError reports that agentstats should exit with Code without printing
+// anything further, e.g. to propagate a child process's exit status.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// NewRunCmd returns the 'run' subcommand.
+func NewRunCmd() *cobra.Command {
+	var dbPath string
+	var configPath string
+	var agentType string
+
+	cmd := &cobra.Command{
+		Use:   "run [flags] -- <command> [args...]",
+		Short: "Run an agent command and record its lifetime as a prompt",
+		Long: `Run an agent command and record its lifetime as a prompt.
+
+For agents without a hook system. Each run creates a new session with a single
+prompt, both spanning the command's lifetime, with git HEAD captured before and
+after.
+The command's exit status is recorded on the prompt and returned by agentstats.`,
+		Args: cobra.MinimumNArgs(1),
+		// The child's exit status is returned as an ExitError; don't print it.
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRun(dbPath, configPath, agentType, args)
+		},
+	}
+
+	// Everything after the command name belongs to the command.
+	cmd.Flags().SetInterspersed(false)
+	cmd.Flags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	cmd.Flags().StringVar(&configPath, "config", "", "Path to config file (default: XDG config dir)")
+	cmd.Flags().StringVar(&agentType, "agent", "", "Agent type to record (default: command name)")
+	return cmd
+}
+
+func runRun(dbPath, configPath, agentType string, args []string) error {
+	if dbPath == "" {
+		dbPath = db.DefaultPath()
+	}
+	if configPath == "" {
+		configPath = config.DefaultPath()
+	}
+	if agentType == "" {
+		agentType = filepath.Base(args[0])
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("get cwd: %w", err)
+	}
+
+	// Like the hooks, recording failures must not stop the agent running:
+	// problems are reported on stderr and the command runs regardless.
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "agentstats: %v; using the default config\n", err)
+		cfg = config.Default()
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "agentstats: open db: %v; not recording\n", err)
+	} else {
+		defer database.Close()
+	}
+
+	input := &hook.HookInput{
+		SessionID:    uuid.New().String(),
+		Cwd:          cwd,
+		PromptText:   strings.Join(args, " "),
+		AgentType:    agentType,
+		EventType:    hook.EventSessionStart,
+		StartSource:  "startup",
+		Subproject:   project.Subproject(cwd, cfg.Subprojects),
+		SkipSnapshot: !cfg.Git.Snapshot(cwd),
+	}
+	if database != nil {
+		if err := hook.RecordSessionStart(database, input); err != nil {
+			fmt.Fprintln(os.Stderr, "agentstats: record session start:", err)
+		}
+		input.EventType = hook.EventPromptStart
+		if err := hook.RecordPromptStart(database, input); err != nil {
+			fmt.Fprintln(os.Stderr, "agentstats: record start:", err)
+		}
+	}
+
+	code, runErr := runChild(args)
+
+	if database != nil {
+		input.EventType = hook.EventPromptEnd
+		input.ExitCode = &code
+		if err := hook.RecordPromptEnd(database, input); err != nil {
+			fmt.Fprintln(os.Stderr, "agentstats: record end:", err)
+		}
+		input.EventType = hook.EventSessionEnd
+		input.EndReason = "exit"
+		if err := hook.RecordSessionEnd(database, input); err != nil {
+			fmt.Fprintln(os.Stderr, "agentstats: record session end:", err)
+		}
+	}
+
+	if runErr != nil {
+		fmt.Fprintln(os.Stderr, "agentstats:", runErr)
+	}
+	if code != 0 {
+		return &ExitError{Code: code}
+	}
+	return nil
+}
+
+// runChild runs args with inherited stdio and returns its exit status. An
+// error is returned only if the command couldn't be started, with status 127
+// as a shell would report.
+func runChild(args []string) (int, error) {
+	child := exec.Command(args[0], args[1:]...)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	// Keep running until the child exits so its end is always recorded.
+	// Interrupts from the terminal reach the child directly via the process
+	// group; termination signals sent to us are forwarded.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	if err := child.Start(); err != nil {
+		return 127, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigs:
+				if sig != os.Interrupt {
+					_ = child.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := child.Wait()
+	close(done)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
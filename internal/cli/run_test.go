@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/dansimau/agentstats/internal/db"
+)
+
+func TestRunRecordsSession(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	configPath := filepath.Join(t.TempDir(), "config.json")
+
+	err := runRun(dbPath, configPath, "test-agent", []string{"sh", "-c", "sleep 1; exit 3"})
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("runRun: got %v, want exit status 3", err)
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	var source, reason string
+	var ended bool
+	var runSeconds float64
+	var exitCode int
+	if err := database.QueryRow(`
+		SELECT s.start_source, s.end_reason, s.ended_at IS NOT NULL, s.run_seconds, p.exit_code
+		FROM sessions s JOIN prompts p ON p.session_id = s.id
+		WHERE s.agent_type = 'test-agent'`,
+	).Scan(&source, &reason, &ended, &runSeconds, &exitCode); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if source != "startup" || reason != "exit" || !ended {
+		t.Errorf("session: source %q, end reason %q, ended %v; want startup, exit, ended", source, reason, ended)
+	}
+	if runSeconds < 1 || runSeconds > 5 {
+		t.Errorf("run_seconds: got %v, want the command's lifetime", runSeconds)
+	}
+	if exitCode != 3 {
+		t.Errorf("exit_code: got %d, want 3", exitCode)
+	}
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// NewSessionsCmd returns the 'sessions' subcommand.
+func NewSessionsCmd() *cobra.Command {
+	var projectDir string
+	var dbPath string
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Show recent sessions for a project with wall-clock and active time",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSessions(dbPath, projectDir, limit)
+		},
+	}
+
+	cmd.Flags().StringVarP(&projectDir, "project", "p", "", "Project directory (default: current directory)")
+	cmd.Flags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "Number of sessions to show")
+	return cmd
+}
+
+type sessionRow struct {
+	startedAt string
+	agentType string
+	length    string // "-" if the session hasn't ended
+	active    string
+	prompts   int
+	source    string
+	endReason string
+}
+
+func runSessions(dbPath, projectDir string, limit int) error {
+	if dbPath == "" {
+		dbPath = db.DefaultPath()
+	}
+	if projectDir == "" {
+		var err error
+		projectDir, err = os.Getwd()
+		if err != nil {
+			return fmt.Errorf("get cwd: %w", err)
+		}
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer database.Close()
+
+	proj, err := project.Find(database, projectDir)
+	if err != nil {
+		return fmt.Errorf("find project: %w", err)
+	}
+	if proj == nil {
+		fmt.Println("No project found for", projectDir)
+		fmt.Println("Run an AI agent in this directory first to start tracking.")
+		return nil
+	}
+
+	rows, err := querySessions(database, proj.ID, limit)
+	if err != nil {
+		return fmt.Errorf("query sessions: %w", err)
+	}
+
+	if len(rows) == 0 {
+		fmt.Println("No sessions recorded yet.")
+		return nil
+	}
+
+	printSessions(rows)
+	return nil
+}
+
+func querySessions(database *sql.DB, projectID string, limit int) ([]sessionRow, error) {
+	sqlRows, err := database.Query(`
+		SELECT
+			strftime('%Y-%m-%d %H:%M:%S', s.started_at),
+			s.agent_type,
+			CASE WHEN s.ended_at IS NULL THEN NULL ELSE s.run_seconds END,
+			COALESCE(SUM(
+				CASE WHEN p.completed_at IS NOT NULL
+				THEN (julianday(p.completed_at) - julianday(p.submitted_at)) * 86400.0
+				ELSE 0 END
+			), 0),
+			COUNT(p.id),
+			COALESCE(s.start_source, ''),
+			COALESCE(s.end_reason, '')
+		FROM sessions s
+		LEFT JOIN prompts p ON p.session_id = s.id
+		WHERE s.project_id = ?
+		GROUP BY s.id
+		ORDER BY s.started_at DESC
+		LIMIT ?
+	`, projectID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlRows.Close()
+
+	var results []sessionRow
+	for sqlRows.Next() {
+		var r sessionRow
+		var lengthSecs sql.NullFloat64
+		var activeSecs float64
+		if err := sqlRows.Scan(
+			&r.startedAt,
+			&r.agentType,
+			&lengthSecs,
+			&activeSecs,
+			&r.prompts,
+			&r.source,
+			&r.endReason,
+		); err != nil {
+			return nil, err
+		}
+		if lengthSecs.Valid {
+			r.length = formatDuration(lengthSecs.Float64)
+		} else {
+			r.length = "-"
+		}
+		r.active = formatDuration(activeSecs)
+		results = append(results, r)
+	}
+	return results, sqlRows.Err()
+}
+
+func printSessions(rows []sessionRow) {
+	// Column widths.
+	const (
+		timeW     = 19
+		agentW    = 12
+		durationW = 12
+		promptsW  = 7
+		sourceW   = 8
+	)
+
+	header := fmt.Sprintf("%-*s  %-*s  %-*s  %-*s  %-*s  %-*s  %s",
+		timeW, "Started",
+		agentW, "Agent",
+		durationW, "Length",
+		durationW, "Active",
+		promptsW, "Prompts",
+		sourceW, "Source",
+		"End reason",
+	)
+	sep := strings.Repeat("-", timeW) + "  " +
+		strings.Repeat("-", agentW) + "  " +
+		strings.Repeat("-", durationW) + "  " +
+		strings.Repeat("-", durationW) + "  " +
+		strings.Repeat("-", promptsW) + "  " +
+		strings.Repeat("-", sourceW) + "  " +
+		strings.Repeat("-", 10)
+
+	fmt.Println(header)
+	fmt.Println(sep)
+
+	for _, r := range rows {
+		fmt.Printf("%-*s  %-*s  %-*s  %-*s  %-*d  %-*s  %s\n",
+			timeW, r.startedAt,
+			agentW, truncate(r.agentType, agentW),
+			durationW, r.length,
+			durationW, r.active,
+			promptsW, r.prompts,
+			sourceW, r.source,
+			r.endReason,
+		)
+	}
+}
@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// NewShowCmd returns the 'show' subcommand.
+func NewShowCmd() *cobra.Command {
+	var dbPath string
+
+	cmd := &cobra.Command{
+		Use:   "show <prompt-id>",
+		Short: "Show a prompt and the subagents it spawned",
+		Long: `Show a prompt and the subagents it spawned, with each subagent's duration
+and share of the prompt's time. The prompt ID may be abbreviated to any unique
+prefix, as shown by 'history'.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runShow(dbPath, args[0])
+		},
+	}
+
+	cmd.Flags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	return cmd
+}
+
+type promptDetail struct {
+	id          string
+	submittedAt string
+	agentType   string
+	seconds     sql.NullFloat64 // null for in-flight
+	promptText  string
+
+	// Working-tree changes; null if the prompt has no snapshots.
+	linesAdded   sql.NullInt64
+	linesRemoved sql.NullInt64
+	filesChanged sql.NullInt64
+
+	// Git state at each end; null if not recorded.
+	branchStart sql.NullString
+	branchEnd   sql.NullString
+	dirtyStart  sql.NullBool
+	dirtyEnd    sql.NullBool
+}
+
+type subagentRow struct {
+	subagentType string
+	description  string
+	seconds      sql.NullFloat64 // null if still running
+}
+
+func runShow(dbPath, idPrefix string) error {
+	if dbPath == "" {
+		dbPath = db.DefaultPath()
+	}
+
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("open db: %w", err)
+	}
+	defer database.Close()
+
+	p, err := queryPromptDetail(database, idPrefix)
+	if err != nil {
+		return err
+	}
+
+	subagents, err := querySubagents(database, p.id)
+	if err != nil {
+		return fmt.Errorf("query subagents: %w", err)
+	}
+
+	printPromptTree(p, subagents)
+	return nil
+}
+
+// queryPromptDetail looks up a prompt by ID or unique ID prefix.
+func queryPromptDetail(database *sql.DB, idPrefix string) (*promptDetail, error) {
+	rows, err := database.Query(`
+		SELECT
+			id,
+			strftime('%Y-%m-%d %H:%M:%S', submitted_at),
+			agent_type,
+			CASE
+				WHEN completed_at IS NULL THEN NULL
+				ELSE (julianday(completed_at) - julianday(submitted_at)) * 86400.0
+			END,
+			COALESCE(prompt_text, ''),
+			lines_added,
+			lines_removed,
+			files_changed,
+			branch_start,
+			branch_end,
+			dirty_start,
+			dirty_end
+		FROM prompts
+		WHERE substr(id, 1, length(?1)) = ?1
+		LIMIT 2
+	`, idPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("query prompt: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []promptDetail
+	for rows.Next() {
+		var p promptDetail
+		if err := rows.Scan(&p.id, &p.submittedAt, &p.agentType, &p.seconds, &p.promptText,
+			&p.linesAdded, &p.linesRemoved, &p.filesChanged,
+			&p.branchStart, &p.branchEnd, &p.dirtyStart, &p.dirtyEnd); err != nil {
+			return nil, err
+		}
+		matches = append(matches, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no prompt with ID %q", idPrefix)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("prompt ID %q is ambiguous; use more characters", idPrefix)
+	}
+}
+
+func querySubagents(database *sql.DB, promptID string) ([]subagentRow, error) {
+	rows, err := database.Query(`
+		SELECT
+			COALESCE(subagent_type, 'subagent'),
+			COALESCE(description, ''),
+			CASE
+				WHEN completed_at IS NULL THEN NULL
+				ELSE (julianday(completed_at) - julianday(started_at)) * 86400.0
+			END
+		FROM subagents
+		WHERE prompt_id = ?
+		ORDER BY started_at
+	`, promptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []subagentRow
+	for rows.Next() {
+		var r subagentRow
+		if err := rows.Scan(&r.subagentType, &r.description, &r.seconds); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+func printPromptTree(p *promptDetail, subagents []subagentRow) {
+	const durationW = 12
+
+	duration := "-"
+	if p.seconds.Valid {
+		duration = formatDuration(math.Round(p.seconds.Float64))
+	}
+
+	fmt.Printf("Prompt:    %s\n", p.id)
+	fmt.Printf("Time:      %s\n", p.submittedAt)
+	fmt.Printf("Agent:     %s\n", p.agentType)
+	fmt.Printf("Duration:  %s\n", duration)
+	if p.branchStart.Valid || p.branchEnd.Valid {
+		start := formatBranch(p.branchStart, p.dirtyStart)
+		end := formatBranch(p.branchEnd, p.dirtyEnd)
+		// Turns reported after they finished have no start state.
+		if start == end || !p.branchStart.Valid {
+			fmt.Printf("Branch:    %s\n", end)
+		} else {
+			fmt.Printf("Branch:    %s -> %s\n", start, end)
+		}
+	}
+	if p.filesChanged.Valid {
+		fmt.Printf("Changes:   +%d -%d in %d files\n", p.linesAdded.Int64, p.linesRemoved.Int64, p.filesChanged.Int64)
+	}
+	fmt.Println()
+
+	fmt.Printf("%-*s  %s\n", durationW, duration, truncate(p.promptText, 60))
+	if len(subagents) == 0 {
+		fmt.Println("(no subagents)")
+		return
+	}
+
+	for i, s := range subagents {
+		branch := "├── "
+		if i == len(subagents)-1 {
+			branch = "└── "
+		}
+
+		dur, share := "-", ""
+		if s.seconds.Valid {
+			dur = formatDuration(math.Round(s.seconds.Float64))
+			if p.seconds.Valid && p.seconds.Float64 > 0 {
+				share = fmt.Sprintf("%.0f%%", 100*s.seconds.Float64/p.seconds.Float64)
+			}
+		}
+
+		label := s.subagentType
+		if s.description != "" {
+			label += ": " + s.description
+		}
+		fmt.Printf("%s%-*s  %4s  %s\n", branch, durationW-4, dur, share, truncate(label, 60))
+	}
+}
+
+// formatBranch renders a branch and dirty flag, e.g. "main (dirty)".
+func formatBranch(branch sql.NullString, dirty sql.NullBool) string {
+	if !branch.Valid {
+		return "?"
+	}
+	if dirty.Valid && dirty.Bool {
+		return branch.String + " (dirty)"
+	}
+	return branch.String
+}
@@ -4,7 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
+	"github.com/dansimau/agentstats/internal/config"
 	"github.com/dansimau/agentstats/internal/db"
 	"github.com/dansimau/agentstats/internal/project"
 	"github.com/spf13/cobra"
@@ -12,81 +15,212 @@ import (
 
 // NewStatsCmd returns the 'stats' subcommand.
 func NewStatsCmd() *cobra.Command {
-	var projectDir string
-	var dbPath string
+	var opts statsOptions
 
 	cmd := &cobra.Command{
 		Use:   "stats",
 		Short: "Show AI working time statistics for a project",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runStats(dbPath, projectDir)
+			return runStats(opts)
 		},
 	}
 
-	cmd.Flags().StringVarP(&projectDir, "project", "p", "", "Project directory (default: current directory)")
-	cmd.Flags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	cmd.Flags().StringVarP(&opts.projectDir, "project", "p", "", "Project directory, or a directory below its root to report just that subtree (default: current directory)")
+	cmd.Flags().StringVar(&opts.dbPath, "db", "", "Path to database (default: XDG data dir)")
+	cmd.Flags().StringVar(&opts.configPath, "config", "", "Path to config file (default: XDG config dir)")
+	cmd.Flags().StringVarP(&opts.branch, "branch", "b", "", "Only include prompts started on this branch")
+	cmd.Flags().StringVar(&opts.groupBy, "group-by", "", "Also break the totals down by: branch, worktree, subproject, or with --all, org")
+	cmd.Flags().StringVar(&opts.since, "since", "", "Only include prompts submitted from this time, e.g. 2024-02-15, 7d, today, last-week")
+	cmd.Flags().StringVar(&opts.until, "until", "", "Only include prompts submitted before the end of this time, e.g. 2024-02-15, yesterday")
+	cmd.Flags().BoolVarP(&opts.all, "all", "a", false, "Report on every project, with a per-project breakdown")
+	cmd.Flags().IntVar(&opts.top, "top", 0, "Only list the N groups or projects with the most working time (default: all)")
+	cmd.MarkFlagsMutuallyExclusive("all", "project")
 	return cmd
 }
 
+type statsOptions struct {
+	dbPath     string
+	configPath string
+	projectDir string
+	branch     string
+	groupBy    string
+	since      string
+	until      string
+	all        bool // every project rather than projectDir's
+	top        int  // rows to list in breakdowns; 0 for all
+}
+
 type statsResult struct {
-	totalPrompts    int
+	totalPrompts     int
 	completedPrompts int
-	totalSeconds    float64
-	firstSubmit     string
-	lastSubmit      string
+	totalSeconds     float64
+	firstSubmit      string
+	lastSubmit       string
+	waitSeconds      float64 // time completed prompts spent blocked on the user
+
+	// Prompt counts by status.
+	interruptedPrompts int
+	timedOutPrompts    int
+	inFlightPrompts    int
+
+	linesAdded   int64
+	linesRemoved int64
+
+	inputTokens      int64
+	outputTokens     int64
+	cacheReadTokens  int64
+	cacheWriteTokens int64
 }
 
-func runStats(dbPath, projectDir string) error {
-	if dbPath == "" {
-		dbPath = db.DefaultPath()
+func runStats(opts statsOptions) error {
+	if opts.dbPath == "" {
+		opts.dbPath = db.DefaultPath()
+	}
+	if opts.configPath == "" {
+		opts.configPath = config.DefaultPath()
 	}
-	if projectDir == "" {
+	// An explicit --project below the project root selects that subtree.
+	subtree := opts.projectDir != ""
+	if opts.projectDir == "" {
 		var err error
-		projectDir, err = os.Getwd()
+		opts.projectDir, err = os.Getwd()
 		if err != nil {
 			return fmt.Errorf("get cwd: %w", err)
 		}
 	}
 
-	database, err := db.Open(dbPath)
-	if err != nil {
-		return fmt.Errorf("open db: %w", err)
+	// Breakdowns only exist with --all or --group-by.
+	if opts.top < 0 {
+		return fmt.Errorf("--top must be positive, got %d", opts.top)
+	}
+	if opts.top > 0 && !opts.all && opts.groupBy == "" {
+		return fmt.Errorf("--top requires --all or --group-by")
 	}
-	defer database.Close()
 
-	proj, err := project.Find(database, projectDir)
-	if err != nil {
-		return fmt.Errorf("find project: %w", err)
+	// Organisations come from project origins, so they are only grouped
+	// across projects.
+	var groupExpr string
+	if opts.groupBy == groupByOrg {
+		if !opts.all {
+			return fmt.Errorf("--group-by %s requires --all", groupByOrg)
+		}
+	} else if opts.groupBy != "" {
+		var err error
+		if groupExpr, err = groupingExpr(opts.groupBy); err != nil {
+			return err
+		}
 	}
-	if proj == nil {
-		fmt.Println("No project found for", projectDir)
-		fmt.Println("Run an AI agent in this directory first to start tracking.")
-		return nil
+
+	cfg, err := config.Load(opts.configPath)
+	if err != nil {
+		return err
 	}
 
-	stats, err := queryStats(database, proj.ID)
+	database, err := db.Open(opts.dbPath)
 	if err != nil {
-		return fmt.Errorf("query stats: %w", err)
+		return fmt.Errorf("open db: %w", err)
 	}
+	defer database.Close()
 
-	fmt.Printf("Project:               %s", proj.ShortName())
-	if proj.DisplayOrigin() != "" {
-		fmt.Printf(" (%s)", proj.DisplayOrigin())
+	filter := promptFilter{branch: opts.branch}
+	if err := filter.setTimeRange(opts.since, opts.until); err != nil {
+		return err
 	}
-	fmt.Println()
 
-	if proj.GitOrigin != "" {
-		fmt.Printf("Git origin:            %s\n", proj.GitOrigin)
+	var projects []*project.Project
+	if opts.all {
+		if projects, err = project.List(database); err != nil {
+			return err
+		}
+		fmt.Printf("Projects:              %d\n", len(projects))
+	} else {
+		proj, err := project.Find(database, opts.projectDir)
+		if err != nil {
+			return fmt.Errorf("find project: %w", err)
+		}
+		if proj == nil {
+			fmt.Println("No project found for", opts.projectDir)
+			fmt.Println("Run an AI agent in this directory first to start tracking.")
+			return nil
+		}
+		filter.projectID = proj.ID
+		if subtree {
+			filter.subdir = project.Subdir(opts.projectDir)
+		}
+
+		fmt.Printf("Project:               %s", proj.ShortName())
+		if proj.DisplayOrigin() != "" {
+			fmt.Printf(" (%s)", proj.DisplayOrigin())
+		}
+		fmt.Println()
+
+		if proj.GitOrigin != "" {
+			fmt.Printf("Git origin:            %s\n", proj.GitOrigin)
+		}
+		if filter.subdir != "" {
+			fmt.Printf("Subtree:               %s\n", filter.subdir)
+		}
+	}
+	if opts.branch != "" {
+		fmt.Printf("Branch:                %s\n", opts.branch)
+	}
+	if r := filter.timeRange(); r != "" {
+		fmt.Printf("Range:                 %s\n", r)
+	}
+
+	stats, err := queryStats(database, filter)
+	if err != nil {
+		return fmt.Errorf("query stats: %w", err)
 	}
 
 	fmt.Printf("Total prompts:         %d\n", stats.totalPrompts)
+	if stats.interruptedPrompts+stats.timedOutPrompts+stats.inFlightPrompts > 0 {
+		fmt.Printf("Prompts by status:     %d completed, %d interrupted, %d timed out, %d in flight\n",
+			stats.totalPrompts-stats.interruptedPrompts-stats.timedOutPrompts-stats.inFlightPrompts,
+			stats.interruptedPrompts,
+			stats.timedOutPrompts,
+			stats.inFlightPrompts,
+		)
+	}
 	fmt.Printf("Total AI working time: %s\n", formatDuration(stats.totalSeconds))
+	fmt.Printf("Waiting on user:       %s\n", formatDuration(stats.waitSeconds))
+	fmt.Printf("Net AI working time:   %s\n", formatDuration(stats.totalSeconds-stats.waitSeconds))
 
 	if stats.completedPrompts > 0 {
 		avg := stats.totalSeconds / float64(stats.completedPrompts)
 		fmt.Printf("Average per prompt:    %s\n", formatDuration(avg))
 	}
 
+	if changed := stats.linesAdded + stats.linesRemoved; changed > 0 {
+		fmt.Printf("Lines changed:         +%d -%d", stats.linesAdded, stats.linesRemoved)
+		if net := stats.totalSeconds - stats.waitSeconds; net > 0 {
+			fmt.Printf(" (%.0f per hour)", float64(changed)/(net/3600))
+		}
+		fmt.Println()
+	}
+
+	if stats.inputTokens+stats.outputTokens+stats.cacheReadTokens+stats.cacheWriteTokens > 0 {
+		fmt.Printf("Tokens:                %s in, %s out, %s cache read, %s cache write\n",
+			formatTokens(stats.inputTokens),
+			formatTokens(stats.outputTokens),
+			formatTokens(stats.cacheReadTokens),
+			formatTokens(stats.cacheWriteTokens),
+		)
+	}
+
+	usage, err := queryModelUsage(database, filter)
+	if err != nil {
+		return fmt.Errorf("query usage: %w", err)
+	}
+	if len(usage) > 0 {
+		total, unpriced := totalCost(usage, cfg.Pricing)
+		fmt.Printf("Estimated cost:        %s", formatCost(total))
+		if unpriced {
+			fmt.Print(" (excludes models without a price)")
+		}
+		fmt.Println()
+	}
+
 	if stats.firstSubmit != "" && stats.lastSubmit != "" {
 		period := stats.firstSubmit
 		if stats.firstSubmit != stats.lastSubmit {
@@ -95,24 +229,79 @@ func runStats(dbPath, projectDir string) error {
 		fmt.Printf("Time period:           %s\n", period)
 	}
 
+	if opts.all {
+		byProject, err := queryProjectGroups(database, filter, projects)
+		if err != nil {
+			return fmt.Errorf("query projects: %w", err)
+		}
+		fmt.Println()
+		rows := make([]groupRow, len(byProject))
+		for i, r := range byProject {
+			rows[i] = r.groupRow
+		}
+		printGroups("project", topGroups(rows, opts.top))
+
+		if opts.groupBy == groupByOrg {
+			fmt.Println()
+			printGroups(groupByOrg, topGroups(orgGroups(byProject), opts.top))
+		}
+	}
+
+	if groupExpr != "" {
+		groups, err := queryGroups(database, filter, groupExpr)
+		if err != nil {
+			return fmt.Errorf("query groups: %w", err)
+		}
+		fmt.Println()
+		printGroups(opts.groupBy, topGroups(groups, opts.top))
+	}
+
 	return nil
 }
 
-func queryStats(database *sql.DB, projectID string) (*statsResult, error) {
+// promptSeconds is the SQL for a prompt's duration in seconds, or 0 if it
+// hasn't finished.
+const promptSeconds = `
+	CASE WHEN prompts.completed_at IS NOT NULL
+	THEN (julianday(prompts.completed_at) - julianday(prompts.submitted_at)) * 86400.0
+	ELSE 0 END`
+
+// promptWaitSeconds is the SQL for the time in seconds a finished prompt spent
+// blocked on the user, or 0 if it hasn't finished.
+const promptWaitSeconds = `
+	CASE WHEN prompts.completed_at IS NOT NULL
+	THEN COALESCE((
+		SELECT SUM(MAX(0, (
+			julianday(MIN(COALESCE(w.ended_at, prompts.completed_at), prompts.completed_at))
+			- julianday(w.started_at)
+		) * 86400.0))
+		FROM prompt_waits w
+		WHERE w.prompt_id = prompts.id
+	), 0)
+	ELSE 0 END`
+
+func queryStats(database *sql.DB, filter promptFilter) (*statsResult, error) {
+	where, args := filter.where()
+	args = append([]interface{}{db.StatusInterrupted, db.StatusTimedOut, db.StatusInFlight}, args...)
 	row := database.QueryRow(`
 		SELECT
 			COUNT(*),
 			COUNT(completed_at),
-			COALESCE(SUM(
-				CASE WHEN completed_at IS NOT NULL
-				THEN (julianday(completed_at) - julianday(submitted_at)) * 86400.0
-				ELSE 0 END
-			), 0),
+			COALESCE(SUM(`+promptSeconds+`), 0),
 			COALESCE(MIN(DATE(submitted_at)), ''),
-			COALESCE(MAX(DATE(submitted_at)), '')
+			COALESCE(MAX(DATE(submitted_at)), ''),
+			COALESCE(SUM(input_tokens), 0),
+			COALESCE(SUM(output_tokens), 0),
+			COALESCE(SUM(cache_read_tokens), 0),
+			COALESCE(SUM(cache_write_tokens), 0),
+			COALESCE(SUM(`+promptWaitSeconds+`), 0),
+			COUNT(CASE WHEN status = ? THEN 1 END),
+			COUNT(CASE WHEN status = ? THEN 1 END),
+			COUNT(CASE WHEN status = ? THEN 1 END),
+			COALESCE(SUM(lines_added), 0),
+			COALESCE(SUM(lines_removed), 0)
 		FROM prompts
-		WHERE project_id = ?
-	`, projectID)
+		WHERE `+where, args...)
 
 	var r statsResult
 	if err := row.Scan(
@@ -121,12 +310,172 @@ func queryStats(database *sql.DB, projectID string) (*statsResult, error) {
 		&r.totalSeconds,
 		&r.firstSubmit,
 		&r.lastSubmit,
+		&r.inputTokens,
+		&r.outputTokens,
+		&r.cacheReadTokens,
+		&r.cacheWriteTokens,
+		&r.waitSeconds,
+		&r.interruptedPrompts,
+		&r.timedOutPrompts,
+		&r.inFlightPrompts,
+		&r.linesAdded,
+		&r.linesRemoved,
 	); err != nil {
 		return nil, err
 	}
 	return &r, nil
 }
 
+// groupRow is the totals for one group of a --group-by breakdown.
+type groupRow struct {
+	key          string
+	prompts      int
+	seconds      float64
+	waitSeconds  float64
+	linesChanged int64
+}
+
+func queryGroups(database *sql.DB, filter promptFilter, groupExpr string) ([]groupRow, error) {
+	where, args := filter.where()
+	rows, err := database.Query(`
+		SELECT
+			COALESCE(`+groupExpr+`, '(none)'),
+			COUNT(*),
+			COALESCE(SUM(`+promptSeconds+`), 0),
+			COALESCE(SUM(`+promptWaitSeconds+`), 0),
+			COALESCE(SUM(lines_added + lines_removed), 0)
+		FROM prompts
+		WHERE `+where+`
+		GROUP BY 1
+		ORDER BY 3 DESC, 1
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []groupRow
+	for rows.Next() {
+		var g groupRow
+		if err := rows.Scan(&g.key, &g.prompts, &g.seconds, &g.waitSeconds, &g.linesChanged); err != nil {
+			return nil, err
+		}
+		results = append(results, g)
+	}
+	return results, rows.Err()
+}
+
+// projectGroupRow is the totals for one project.
+type projectGroupRow struct {
+	groupRow
+	proj *project.Project
+}
+
+// queryProjectGroups returns the totals for each of projects with filtered
+// prompts, most working time first, keyed by project name. Projects that
+// share a name are told apart by their short ID.
+func queryProjectGroups(database *sql.DB, filter promptFilter, projects []*project.Project) ([]projectGroupRow, error) {
+	groups, err := queryGroups(database, filter, "prompts.project_id")
+	if err != nil {
+		return nil, err
+	}
+	byID := map[string]*project.Project{}
+	names := map[string]int{}
+	for _, p := range projects {
+		byID[p.ID] = p
+		names[p.ShortName()]++
+	}
+
+	var results []projectGroupRow
+	for _, g := range groups {
+		p, ok := byID[g.key]
+		if !ok {
+			continue
+		}
+		g.key = p.ShortName()
+		if names[g.key] > 1 {
+			g.key += " (" + truncateID(p.ID, 8) + ")"
+		}
+		results = append(results, projectGroupRow{groupRow: g, proj: p})
+	}
+	return results, nil
+}
+
+// groupByOrg is the --group-by value that totals projects by the
+// organisation in their git origin (see project.Org).
+const groupByOrg = "org"
+
+// orgGroups totals per-project rows by organisation, most working time
+// first.
+func orgGroups(byProject []projectGroupRow) []groupRow {
+	var results []groupRow
+	index := map[string]int{}
+	for _, r := range byProject {
+		org := r.proj.Org()
+		if org == "" {
+			org = "(none)"
+		}
+		i, ok := index[org]
+		if !ok {
+			i = len(results)
+			index[org] = i
+			results = append(results, groupRow{key: org})
+		}
+		results[i].prompts += r.prompts
+		results[i].seconds += r.seconds
+		results[i].waitSeconds += r.waitSeconds
+		results[i].linesChanged += r.linesChanged
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].seconds != results[j].seconds {
+			return results[i].seconds > results[j].seconds
+		}
+		return results[i].key < results[j].key
+	})
+	return results
+}
+
+// topGroups returns the first n groups, or all of them if n <= 0.
+func topGroups(groups []groupRow, n int) []groupRow {
+	if n > 0 && len(groups) > n {
+		return groups[:n]
+	}
+	return groups
+}
+
+func printGroups(groupBy string, groups []groupRow) {
+	// Column widths.
+	const (
+		keyW      = 30
+		promptsW  = 7
+		durationW = 12
+		linesW    = 8
+	)
+
+	fmt.Printf("%-*s  %*s  %-*s  %-*s  %*s\n",
+		keyW, strings.ToUpper(groupBy[:1])+groupBy[1:],
+		promptsW, "Prompts",
+		durationW, "Working time",
+		durationW, "Net time",
+		linesW, "Lines",
+	)
+	fmt.Println(strings.Repeat("-", keyW) + "  " +
+		strings.Repeat("-", promptsW) + "  " +
+		strings.Repeat("-", durationW) + "  " +
+		strings.Repeat("-", durationW) + "  " +
+		strings.Repeat("-", linesW))
+
+	for _, g := range groups {
+		fmt.Printf("%-*s  %*d  %-*s  %-*s  %*d\n",
+			keyW, truncate(g.key, keyW),
+			promptsW, g.prompts,
+			durationW, formatDuration(g.seconds),
+			durationW, formatDuration(g.seconds-g.waitSeconds),
+			linesW, g.linesChanged,
+		)
+	}
+}
+
 func formatDuration(seconds float64) string {
 	if seconds <= 0 {
 		return "0s"
@@ -144,3 +493,15 @@ func formatDuration(seconds float64) string {
 	}
 	return fmt.Sprintf("%ds", s)
 }
+
+// formatTokens renders a token count compactly, e.g. 950, 12.3k, 4.5M.
+func formatTokens(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
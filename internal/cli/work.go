@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"database/sql"
+
+	"github.com/dansimau/agentstats/internal/db"
+)
+
+// workedPrompt is a prompt's contribution to a span of time, such as the
+// work between two commits.
+type workedPrompt struct {
+	id          string
+	agentType   string
+	submittedAt string
+	promptText  string
+	seconds     float64 // working time within the span
+}
+
+// promptsBetween returns the project's prompts that were running after after
+// and up to until, both in db.TimeFormat, oldest first. An empty after means
+// from the beginning. Each prompt's time is clipped to the span. Prompts
+// still in flight are left out unless inFlight is set, in which case they
+// count as running until until.
+func promptsBetween(database *sql.DB, projectID, after, until string, inFlight bool) ([]workedPrompt, error) {
+	rows, err := database.Query(`
+		SELECT
+			id,
+			agent_type,
+			strftime('%Y-%m-%d %H:%M:%S', submitted_at),
+			COALESCE(prompt_text, ''),
+			(julianday(MIN(COALESCE(completed_at, ?3), ?3)) - julianday(MAX(submitted_at, ?2))) * 86400.0
+		FROM prompts
+		WHERE project_id = ?1
+		  AND (completed_at IS NOT NULL OR (?5 AND status = ?4))
+		  AND COALESCE(completed_at, ?3) > ?2
+		  AND submitted_at < ?3
+		ORDER BY submitted_at
+	`, projectID, after, until, db.StatusInFlight, inFlight)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []workedPrompt
+	for rows.Next() {
+		var p workedPrompt
+		if err := rows.Scan(&p.id, &p.agentType, &p.submittedAt, &p.promptText, &p.seconds); err != nil {
+			return nil, err
+		}
+		results = append(results, p)
+	}
+	return results, rows.Err()
+}
+
+// totalSeconds sums the working time of prompts.
+func totalSeconds(prompts []workedPrompt) float64 {
+	var total float64
+	for _, p := range prompts {
+		total += p.seconds
+	}
+	return total
+}
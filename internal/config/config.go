@@ -0,0 +1,126 @@
+// Package config loads the optional agentstats configuration file.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dansimau/agentstats/internal/gitx"
+	"github.com/dansimau/agentstats/internal/pricing"
+	"github.com/dansimau/agentstats/internal/project"
+)
+
+// Config is the contents of the agentstats config file. Every field is
+// optional; anything not set keeps its built-in default.
+type Config struct {
+	// Pricing adds to or overrides the built-in model price table.
+	Pricing pricing.Table `json:"pricing"`
+
+	// GC controls cleanup of prompts that never got an end event.
+	GC GC `json:"gc"`
+
+	// Subprojects splits monorepo projects into sub-projects by directory.
+	Subprojects project.SubprojectRules `json:"subprojects"`
+
+	// Git controls what is recorded from the project's repo.
+	Git Git `json:"git"`
+}
+
+// Git is the "git" section of the config file.
+type Git struct {
+	// SnapshotMaxFiles is the most files a repo may have for the working
+	// tree to be snapshotted at each end of a prompt. Snapshotting stages
+	// every file into a temporary index, which is slow on very large repos.
+	// 0 turns snapshots, and so line counts, off.
+	SnapshotMaxFiles int `json:"snapshot_max_files"`
+}
+
+// Snapshot reports whether the working tree of the repo containing dir
+// should be snapshotted.
+func (g Git) Snapshot(dir string) bool {
+	if g.SnapshotMaxFiles <= 0 {
+		return false
+	}
+	// A repo with no index yet is empty, so cheap to snapshot.
+	n, ok := gitx.IndexEntries(dir)
+	return !ok || n <= g.SnapshotMaxFiles
+}
+
+// GC is the "gc" section of the config file.
+type GC struct {
+	// StaleAfter is how long a prompt may stay in flight before it is
+	// marked as timed out.
+	StaleAfter Duration `json:"stale_after"`
+}
+
+// Duration is a time.Duration written in the config file as a string such as
+// "90m" or "6h".
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"6h\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if v <= 0 {
+		return fmt.Errorf("duration must be positive, got %q", s)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// DefaultPath returns the XDG-aware path to the config file.
+func DefaultPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = "."
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "agentstats", "config.json")
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	return &Config{
+		Pricing: pricing.Defaults(),
+		GC: GC{
+			StaleAfter: Duration(6 * time.Hour),
+		},
+		Subprojects: project.SubprojectRules{
+			Detect: []string{"go.mod", "package.json"},
+		},
+		Git: Git{
+			SnapshotMaxFiles: 100000,
+		},
+	}
+}
+
+// Load reads the config file at path over the built-in defaults. A missing
+// file is not an error.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+	// Decoding into the populated config merges file entries over the
+	// defaults; see pricing.Table.UnmarshalJSON.
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
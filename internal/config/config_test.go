@@ -0,0 +1,133 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dansimau/agentstats/internal/config"
+	"github.com/dansimau/agentstats/internal/pricing"
+)
+
+func TestLoad_Missing(t *testing.T) {
+	cfg, err := config.Load(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, ok := cfg.Pricing.Lookup("claude-sonnet-4-20250514"); !ok {
+		t.Error("expected built-in pricing when config file is missing")
+	}
+}
+
+func TestLoad_MergesPricing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"pricing": {
+		"claude-sonnet-4": {"input": 2, "output": 10, "cache_read": 0.2, "cache_write": 2.5},
+		"gpt-5": {"input": 1.25, "output": 10, "cache_read": 0.125}
+	}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if p, _ := cfg.Pricing.Lookup("claude-sonnet-4-5"); p.Input != 2 {
+		t.Errorf("override: got input %v, want 2", p.Input)
+	}
+	if p, ok := cfg.Pricing.Lookup("gpt-5-codex"); !ok || p.Input != 1.25 {
+		t.Errorf("added model: got %+v, %v", p, ok)
+	}
+	if _, ok := cfg.Pricing.Lookup("claude-3-5-haiku-20241022"); !ok {
+		t.Error("defaults not in the file should be kept")
+	}
+}
+
+func TestLoad_PartialPricing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"pricing": {
+		"claude-sonnet-4": {"output": 12},
+		"claude-sonnet-4-5": {"input": 2.5}
+	}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := pricing.Price{Input: 3, Output: 12, CacheRead: 0.30, CacheWrite: 3.75}
+	if p, _ := cfg.Pricing.Lookup("claude-sonnet-4-20250514"); p != want {
+		t.Errorf("partial override: got %+v, want %+v", p, want)
+	}
+	// A new, more specific entry starts from the price its family had.
+	want.Input = 2.5
+	if p, _ := cfg.Pricing.Lookup("claude-sonnet-4-5-20250929"); p != want {
+		t.Errorf("new entry: got %+v, want %+v", p, want)
+	}
+}
+
+func TestLoad_GC(t *testing.T) {
+	cfg, err := config.Load(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := time.Duration(cfg.GC.StaleAfter); got != 6*time.Hour {
+		t.Errorf("default stale_after: got %v, want 6h", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"gc": {"stale_after": "90m"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := time.Duration(cfg.GC.StaleAfter); got != 90*time.Minute {
+		t.Errorf("stale_after: got %v, want 90m", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"gc": {"stale_after": "soon"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Load(path); err == nil {
+		t.Error("expected error for invalid stale_after")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Load(path); err == nil {
+		t.Error("expected error for invalid config")
+	}
+}
+
+func TestLoad_Git(t *testing.T) {
+	cfg, err := config.Load(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	dir := t.TempDir()
+	if !cfg.Git.Snapshot(dir) {
+		t.Error("snapshots should be on by default")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"git": {"snapshot_max_files": 0}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = config.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Git.Snapshot(dir) {
+		t.Error("snapshot_max_files 0 should turn snapshots off")
+	}
+}
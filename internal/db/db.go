@@ -1,10 +1,12 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
@@ -52,5 +54,79 @@ func Open(path string) (*sql.DB, error) {
 		return nil, fmt.Errorf("apply schema: %w", err)
 	}
 
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+
 	return db, nil
 }
+
+// migrate applies any migrations newer than the database's user_version.
+func migrate(db *sql.DB) error {
+	ctx := context.Background()
+
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read user_version: %w", err)
+	}
+	if version >= len(migrations) {
+		return nil
+	}
+
+	// Pin a connection so BEGIN IMMEDIATE and the migrations share it. Taking
+	// the write lock up front stops concurrent hook processes racing to apply
+	// the same migration.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	rollback := func() { _, _ = conn.ExecContext(ctx, "ROLLBACK") }
+
+	// Re-read under the lock; another process may have migrated already.
+	if err := conn.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		rollback()
+		return fmt.Errorf("read user_version: %w", err)
+	}
+	for i := version; i < len(migrations); i++ {
+		if _, err := conn.ExecContext(ctx, migrations[i]); err != nil {
+			rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if fn := migrationFuncs[i+1]; fn != nil {
+			if err := fn(ctx, conn); err != nil {
+				rollback()
+				return fmt.Errorf("migration %d: %w", i+1, err)
+			}
+		}
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", len(migrations))); err != nil {
+		rollback()
+		return fmt.Errorf("set user_version: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		rollback()
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// TimeFormat is the layout SQLite's CURRENT_TIMESTAMP uses. Timestamps written
+// from Go must use it too so they sort and compare correctly in SQL.
+const TimeFormat = "2006-01-02 15:04:05"
+
+// FormatTime formats t as a UTC timestamp in TimeFormat.
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// ParseTime parses a timestamp stored in TimeFormat, with or without
+// fractional seconds, as UTC.
+func ParseTime(s string) (time.Time, error) {
+	return time.Parse(TimeFormat, s)
+}
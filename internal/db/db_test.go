@@ -1,6 +1,9 @@
 package db_test
 
 import (
+	"database/sql"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 
@@ -24,6 +27,213 @@ func TestOpenCreatesDB(t *testing.T) {
 	database2.Close()
 }
 
+func TestOpenMigratesExistingDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentstats.db")
+
+	// Simulate a database created before any migrations existed.
+	raw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	if _, err := raw.Exec(`
+		CREATE TABLE projects (id TEXT PRIMARY KEY, git_origin TEXT, directory TEXT NOT NULL UNIQUE, created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+		CREATE TABLE sessions (id TEXT PRIMARY KEY, project_id TEXT NOT NULL REFERENCES projects(id), agent_type TEXT NOT NULL DEFAULT 'claude-code', started_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+		CREATE TABLE prompts (id TEXT PRIMARY KEY, session_id TEXT NOT NULL REFERENCES sessions(id), project_id TEXT NOT NULL REFERENCES projects(id), prompt_text TEXT, submitted_at DATETIME NOT NULL, completed_at DATETIME, git_hash_start TEXT, git_hash_end TEXT, agent_type TEXT NOT NULL DEFAULT 'claude-code');
+	`); err != nil {
+		t.Fatalf("create legacy schema: %v", err)
+	}
+	raw.Close()
+
+	database, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("Open() on legacy db: %v", err)
+	}
+	defer database.Close()
+
+	var version int
+	if err := database.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version == 0 {
+		t.Error("expected user_version to be bumped by migrations")
+	}
+	if _, err := database.Exec(`SELECT model, input_tokens FROM prompts`); err != nil {
+		t.Errorf("migrated columns missing: %v", err)
+	}
+}
+
+func TestOpenMergesDuplicateOrigins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentstats.db")
+
+	raw, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	if _, err := raw.Exec(`
+		CREATE TABLE projects (id TEXT PRIMARY KEY, git_origin TEXT, directory TEXT NOT NULL UNIQUE, created_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+		CREATE TABLE sessions (id TEXT PRIMARY KEY, project_id TEXT NOT NULL REFERENCES projects(id), agent_type TEXT NOT NULL DEFAULT 'claude-code', started_at DATETIME DEFAULT CURRENT_TIMESTAMP);
+		CREATE TABLE prompts (id TEXT PRIMARY KEY, session_id TEXT NOT NULL REFERENCES sessions(id), project_id TEXT NOT NULL REFERENCES projects(id), prompt_text TEXT, submitted_at DATETIME NOT NULL, completed_at DATETIME, git_hash_start TEXT, git_hash_end TEXT, agent_type TEXT NOT NULL DEFAULT 'claude-code');
+		INSERT INTO projects (id, git_origin, directory, created_at) VALUES
+		    ('ssh',   'git@github.com:org/repo.git', '/src/repo',   '2026-01-01 00:00:00'),
+		    ('https', 'https://github.com/Org/repo', '/src/repo-2', '2026-01-02 00:00:00'),
+		    ('other', 'git@github.com:org/other.git', '/src/other', '2026-01-03 00:00:00'),
+		    ('local', NULL, '/src/local', '2026-01-04 00:00:00');
+		INSERT INTO sessions (id, project_id) VALUES ('s1', 'ssh'), ('s2', 'https');
+		INSERT INTO prompts (id, session_id, project_id, submitted_at) VALUES
+		    ('p1', 's1', 'ssh', '2026-01-01 00:00:00'),
+		    ('p2', 's2', 'https', '2026-01-02 00:00:00');
+	`); err != nil {
+		t.Fatalf("create legacy schema: %v", err)
+	}
+	raw.Close()
+
+	database, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("Open() on legacy db: %v", err)
+	}
+	defer database.Close()
+
+	got := map[string]string{}
+	rows, err := database.Query(`SELECT id, COALESCE(origin_key, '') FROM projects`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, key string
+		if err := rows.Scan(&id, &key); err != nil {
+			t.Fatal(err)
+		}
+		got[id] = key
+	}
+	want := map[string]string{
+		"ssh":   "github.com/org/repo",
+		"other": "github.com/org/other",
+		"local": "",
+	}
+	if len(got) != len(want) {
+		t.Errorf("projects after merge: got %v, want %v", got, want)
+	}
+	for id, key := range want {
+		if got[id] != key {
+			t.Errorf("project %s: got origin key %q, want %q", id, got[id], key)
+		}
+	}
+
+	var moved int
+	if err := database.QueryRow(
+		`SELECT (SELECT COUNT(*) FROM sessions WHERE project_id = 'ssh') + (SELECT COUNT(*) FROM prompts WHERE project_id = 'ssh')`,
+	).Scan(&moved); err != nil {
+		t.Fatal(err)
+	}
+	if moved != 4 {
+		t.Errorf("expected both sessions and prompts under the surviving project, got %d rows", moved)
+	}
+}
+
+func TestOpenMergesWorktreeProjects(t *testing.T) {
+	// newRepo creates a repo with a linked worktree and returns both paths.
+	newRepo := func(branch string) (repo, linked string) {
+		t.Helper()
+		repo, err := filepath.EvalSymlinks(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		linked = filepath.Join(t.TempDir(), branch)
+		for _, args := range [][]string{
+			{"init", "-q"},
+			{"commit", "-q", "--allow-empty", "-m", "init"},
+			{"worktree", "add", "-q", linked},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = repo
+			cmd.Env = append(os.Environ(),
+				"GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@test.com",
+				"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@test.com",
+			)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v\n%s", args, err, out)
+			}
+		}
+		linked, err = filepath.EvalSymlinks(linked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repo, linked
+	}
+	repo, linked := newRepo("feature")
+	// A second repo whose only project is for a linked worktree.
+	other, otherLinked := newRepo("fix")
+
+	path := filepath.Join(t.TempDir(), "agentstats.db")
+	database, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	// Projects as recorded before linked worktrees shared one, with the
+	// database rolled back to the version before the merge.
+	for _, stmt := range []string{
+		`INSERT INTO projects (id, directory, name, created_at) VALUES
+		     ('main', '` + repo + `', NULL, '2026-01-01 00:00:00'),
+		     ('wt', '` + linked + `', 'feature', '2026-01-02 00:00:00'),
+		     ('other-wt', '` + otherLinked + `', NULL, '2026-01-03 00:00:00'),
+		     ('gone', '/nonexistent/dir', NULL, '2026-01-04 00:00:00')`,
+		`INSERT INTO sessions (id, project_id) VALUES ('s1', 'main'), ('s2', 'wt')`,
+		`INSERT INTO prompts (id, session_id, project_id, submitted_at) VALUES
+		     ('p1', 's1', 'main', '2026-01-01 00:00:00'),
+		     ('p2', 's2', 'wt', '2026-01-02 00:00:00')`,
+		`PRAGMA user_version = 12`,
+	} {
+		if _, err := database.Exec(stmt); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+	database.Close()
+
+	database, err = db.Open(path)
+	if err != nil {
+		t.Fatalf("Open() to migrate: %v", err)
+	}
+	defer database.Close()
+
+	got := map[string]string{}
+	rows, err := database.Query(`SELECT id, directory || ' ' || COALESCE(name, '') FROM projects`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, v string
+		if err := rows.Scan(&id, &v); err != nil {
+			t.Fatal(err)
+		}
+		got[id] = v
+	}
+	want := map[string]string{
+		"main":     repo + " feature",
+		"other-wt": other + " ",
+		"gone":     "/nonexistent/dir ",
+	}
+	if len(got) != len(want) {
+		t.Errorf("projects after merge: got %v, want %v", got, want)
+	}
+	for id, v := range want {
+		if got[id] != v {
+			t.Errorf("project %s: got %q, want %q", id, got[id], v)
+		}
+	}
+
+	var moved int
+	if err := database.QueryRow(
+		`SELECT (SELECT COUNT(*) FROM sessions WHERE project_id = 'main') + (SELECT COUNT(*) FROM prompts WHERE project_id = 'main')`,
+	).Scan(&moved); err != nil {
+		t.Fatal(err)
+	}
+	if moved != 4 {
+		t.Errorf("expected both sessions and prompts under the main worktree's project, got %d rows", moved)
+	}
+}
+
 func TestDefaultPath(t *testing.T) {
 	p := db.DefaultPath()
 	if p == "" {
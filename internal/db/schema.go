@@ -1,5 +1,21 @@
 package db
 
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dansimau/agentstats/internal/gitx"
+)
+
+// Prompt statuses, stored in prompts.status.
+const (
+	StatusInFlight    = "in-flight"
+	StatusCompleted   = "completed"
+	StatusInterrupted = "interrupted" // superseded by a new prompt without an end event
+	StatusTimedOut    = "timed-out"
+)
+
 const schema = `
 CREATE TABLE IF NOT EXISTS projects (
     id          TEXT PRIMARY KEY,
@@ -8,6 +24,12 @@ CREATE TABLE IF NOT EXISTS projects (
     created_at  DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Further origin keys of a project, e.g. from a duplicate merged into it.
+CREATE TABLE IF NOT EXISTS project_origins (
+    origin_key  TEXT PRIMARY KEY,
+    project_id  TEXT NOT NULL REFERENCES projects(id)
+);
+
 CREATE TABLE IF NOT EXISTS sessions (
     id          TEXT PRIMARY KEY,
     project_id  TEXT NOT NULL REFERENCES projects(id),
@@ -27,7 +49,255 @@ CREATE TABLE IF NOT EXISTS prompts (
     agent_type      TEXT NOT NULL DEFAULT 'claude-code'
 );
 
+CREATE TABLE IF NOT EXISTS tool_calls (
+    id             TEXT PRIMARY KEY,
+    prompt_id      TEXT NOT NULL REFERENCES prompts(id),
+    session_id     TEXT NOT NULL REFERENCES sessions(id),
+    tool_name      TEXT NOT NULL,
+    tool_input     TEXT,
+    tool_response  TEXT,
+    started_at     DATETIME NOT NULL,
+    completed_at   DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS prompt_waits (
+    id          TEXT PRIMARY KEY,
+    prompt_id   TEXT NOT NULL REFERENCES prompts(id),
+    session_id  TEXT NOT NULL REFERENCES sessions(id),
+    kind        TEXT,
+    message     TEXT,
+    started_at  DATETIME NOT NULL,
+    ended_at    DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS subagents (
+    id             TEXT PRIMARY KEY,
+    prompt_id      TEXT NOT NULL REFERENCES prompts(id),
+    session_id     TEXT NOT NULL REFERENCES sessions(id),
+    tool_use_id    TEXT,
+    agent_id       TEXT,
+    subagent_type  TEXT,
+    description    TEXT,
+    started_at     DATETIME NOT NULL,
+    completed_at   DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS prompt_files (
+    prompt_id      TEXT NOT NULL REFERENCES prompts(id),
+    path           TEXT NOT NULL,
+    lines_added    INTEGER NOT NULL,
+    lines_removed  INTEGER NOT NULL,
+    PRIMARY KEY (prompt_id, path)
+);
+
+CREATE TABLE IF NOT EXISTS prompt_commits (
+    prompt_id            TEXT NOT NULL REFERENCES prompts(id),
+    hash                 TEXT NOT NULL,
+    subject              TEXT,
+    author               TEXT,
+    committed_at         DATETIME NOT NULL,
+    parent_committed_at  DATETIME,
+    files_changed        INTEGER NOT NULL,
+    insertions           INTEGER NOT NULL,
+    deletions            INTEGER NOT NULL,
+    PRIMARY KEY (prompt_id, hash)
+);
+
 CREATE INDEX IF NOT EXISTS idx_prompts_session   ON prompts(session_id);
 CREATE INDEX IF NOT EXISTS idx_prompts_project   ON prompts(project_id);
 CREATE INDEX IF NOT EXISTS idx_prompts_submitted ON prompts(submitted_at);
+CREATE INDEX IF NOT EXISTS idx_tool_calls_prompt  ON tool_calls(prompt_id);
+CREATE INDEX IF NOT EXISTS idx_tool_calls_session ON tool_calls(session_id);
+CREATE INDEX IF NOT EXISTS idx_prompt_waits_prompt  ON prompt_waits(prompt_id);
+CREATE INDEX IF NOT EXISTS idx_prompt_waits_session ON prompt_waits(session_id);
+CREATE INDEX IF NOT EXISTS idx_subagents_prompt     ON subagents(prompt_id);
+CREATE INDEX IF NOT EXISTS idx_subagents_session    ON subagents(session_id);
+CREATE INDEX IF NOT EXISTS idx_prompt_files_path    ON prompt_files(path);
+CREATE INDEX IF NOT EXISTS idx_prompt_commits_hash  ON prompt_commits(hash);
 `
+
+// migrations upgrade databases created by older versions. Entry i takes a
+// database from schema version i to i+1; the current version is stored in
+// PRAGMA user_version. Tables in schema above are never altered in place:
+// column changes go here, and released entries must never be edited.
+var migrations = []string{
+	// 1: token usage and model per prompt, read from the agent transcript.
+	`ALTER TABLE prompts ADD COLUMN model TEXT;
+	 ALTER TABLE prompts ADD COLUMN input_tokens INTEGER;
+	 ALTER TABLE prompts ADD COLUMN output_tokens INTEGER;
+	 ALTER TABLE prompts ADD COLUMN cache_read_tokens INTEGER;
+	 ALTER TABLE prompts ADD COLUMN cache_write_tokens INTEGER;`,
+
+	// 2: exit status of prompts recorded by 'agentstats run'.
+	`ALTER TABLE prompts ADD COLUMN exit_code INTEGER;`,
+
+	// 3: session lifecycle from SessionStart/SessionEnd events.
+	`ALTER TABLE sessions ADD COLUMN ended_at DATETIME;
+	 ALTER TABLE sessions ADD COLUMN start_source TEXT;
+	 ALTER TABLE sessions ADD COLUMN end_reason TEXT;`,
+
+	// 4: prompt status, so prompts that never got an end event can be closed.
+	`ALTER TABLE prompts ADD COLUMN status TEXT NOT NULL DEFAULT 'in-flight';
+	 UPDATE prompts SET status = 'completed' WHERE completed_at IS NOT NULL;
+	 CREATE INDEX IF NOT EXISTS idx_prompts_status ON prompts(status);`,
+
+	// 5: transcript location, used to bound the duration of stale prompts.
+	`ALTER TABLE sessions ADD COLUMN transcript_path TEXT;`,
+
+	// 6: working-tree snapshots and the lines changed between them.
+	`ALTER TABLE prompts ADD COLUMN tree_start TEXT;
+	 ALTER TABLE prompts ADD COLUMN tree_end TEXT;
+	 ALTER TABLE prompts ADD COLUMN lines_added INTEGER;
+	 ALTER TABLE prompts ADD COLUMN lines_removed INTEGER;
+	 ALTER TABLE prompts ADD COLUMN files_changed INTEGER;`,
+
+	// 7: checked-out branch and whether the tree was dirty at each end.
+	`ALTER TABLE prompts ADD COLUMN branch_start TEXT;
+	 ALTER TABLE prompts ADD COLUMN branch_end TEXT;
+	 ALTER TABLE prompts ADD COLUMN dirty_start INTEGER;
+	 ALTER TABLE prompts ADD COLUMN dirty_end INTEGER;
+	 CREATE INDEX IF NOT EXISTS idx_prompts_branch ON prompts(branch_start);`,
+
+	// 8: the git worktree a prompt ran in, which may be a linked worktree
+	// of the project's repo.
+	`ALTER TABLE prompts ADD COLUMN worktree TEXT;`,
+
+	// 9: where in the repo a prompt ran, for monorepo sub-projects.
+	`ALTER TABLE prompts ADD COLUMN subdir TEXT;
+	 ALTER TABLE prompts ADD COLUMN subproject TEXT;`,
+
+	// 10: canonical origin key, so ssh and https clones match one project.
+	// Filled in, merging duplicates, by mergeOrigins.
+	`ALTER TABLE projects ADD COLUMN origin_key TEXT;
+	 CREATE INDEX IF NOT EXISTS idx_projects_origin_key ON projects(origin_key);`,
+
+	// 11: display name set with 'projects rename'.
+	`ALTER TABLE projects ADD COLUMN name TEXT;`,
+
+	// 12: runs of a resumed session, so its length leaves out the time
+	// between them.
+	`ALTER TABLE sessions ADD COLUMN resumed_at DATETIME;
+	 ALTER TABLE sessions ADD COLUMN run_seconds REAL;
+	 UPDATE sessions SET run_seconds = (julianday(ended_at) - julianday(started_at)) * 86400.0
+	 WHERE ended_at IS NOT NULL;`,
+
+	// 13: one project per repo without an origin, rather than one per linked
+	// worktree. There is no schema change; mergeWorktrees does the work.
+	`SELECT 1;`,
+
+	// 14: trim tool call inputs and responses recorded in full before they
+	// were capped (to 4096 characters here; new ones are capped in bytes).
+	`UPDATE tool_calls SET tool_input = substr(tool_input, 1, 4096) WHERE length(tool_input) > 4096;
+	 UPDATE tool_calls SET tool_response = substr(tool_response, 1, 4096) WHERE length(tool_response) > 4096;`,
+}
+
+// migrationFuncs run after the migration with the same number, for changes
+// SQL alone can't express.
+var migrationFuncs = map[int]func(context.Context, *sql.Conn) error{
+	10: mergeOrigins,
+	13: mergeWorktrees,
+}
+
+// mergeOrigins sets origin_key on every project with an origin and merges
+// projects whose origins share a key into the oldest of them.
+func mergeOrigins(ctx context.Context, conn *sql.Conn) error {
+	rows, err := conn.QueryContext(ctx,
+		`SELECT id, git_origin FROM projects WHERE git_origin IS NOT NULL AND git_origin != ''
+		 ORDER BY created_at, rowid`)
+	if err != nil {
+		return fmt.Errorf("query projects: %w", err)
+	}
+	keys := map[string]string{} // project ID -> origin key
+	var ids []string
+	for rows.Next() {
+		var id, origin string
+		if err := rows.Scan(&id, &origin); err != nil {
+			rows.Close()
+			return err
+		}
+		keys[id] = gitx.OriginKey(origin)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	keep := map[string]string{} // origin key -> surviving project ID
+	for _, id := range ids {
+		key := keys[id]
+		into, ok := keep[key]
+		if !ok {
+			keep[key] = id
+			if _, err := conn.ExecContext(ctx, `UPDATE projects SET origin_key = ? WHERE id = ?`, key, id); err != nil {
+				return fmt.Errorf("set origin key: %w", err)
+			}
+			continue
+		}
+		for _, stmt := range []string{
+			`UPDATE sessions SET project_id = ?1 WHERE project_id = ?2`,
+			`UPDATE prompts SET project_id = ?1 WHERE project_id = ?2`,
+			`DELETE FROM projects WHERE id = ?2`,
+		} {
+			if _, err := conn.ExecContext(ctx, stmt, into, id); err != nil {
+				return fmt.Errorf("merge project %s into %s: %w", id, into, err)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeWorktrees moves each project without an origin to the main worktree
+// of its repo, merging projects created for linked worktrees into the main
+// worktree's project. Directories that no longer exist are left alone.
+func mergeWorktrees(ctx context.Context, conn *sql.Conn) error {
+	rows, err := conn.QueryContext(ctx,
+		`SELECT id, directory FROM projects WHERE origin_key IS NULL ORDER BY created_at, rowid`)
+	if err != nil {
+		return fmt.Errorf("query projects: %w", err)
+	}
+	type proj struct{ id, dir string }
+	var projects []proj
+	for rows.Next() {
+		var p proj
+		if err := rows.Scan(&p.id, &p.dir); err != nil {
+			rows.Close()
+			return err
+		}
+		projects = append(projects, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range projects {
+		main := gitx.MainWorktree(p.dir)
+		if main == "" || main == p.dir {
+			continue
+		}
+		var into string
+		err := conn.QueryRowContext(ctx, `SELECT id FROM projects WHERE directory = ?`, main).Scan(&into)
+		if err == sql.ErrNoRows {
+			if _, err := conn.ExecContext(ctx, `UPDATE projects SET directory = ? WHERE id = ?`, main, p.id); err != nil {
+				return fmt.Errorf("move project %s: %w", p.id, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("query project: %w", err)
+		}
+		for _, stmt := range []string{
+			`UPDATE projects SET name = COALESCE(name, (SELECT name FROM projects WHERE id = ?2)) WHERE id = ?1`,
+			`UPDATE sessions SET project_id = ?1 WHERE project_id = ?2`,
+			`UPDATE prompts SET project_id = ?1 WHERE project_id = ?2`,
+			`UPDATE project_origins SET project_id = ?1 WHERE project_id = ?2`,
+			`DELETE FROM projects WHERE id = ?2`,
+		} {
+			if _, err := conn.ExecContext(ctx, stmt, into, p.id); err != nil {
+				return fmt.Errorf("merge project %s into %s: %w", p.id, into, err)
+			}
+		}
+	}
+	return nil
+}
@@ -2,9 +2,16 @@ package gitx
 
 import (
 	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // IsRepo reports whether dir is inside a git repository.
@@ -27,6 +34,28 @@ func RepoRoot(dir string) string {
 	return root
 }
 
+// MainWorktree returns the canonical top-level directory of the main
+// worktree of the repo containing dir. Every linked worktree (see 'git
+// worktree') resolves to the same directory; in the main worktree this is the
+// same as RepoRoot. For a bare repo it is the repo directory. Returns "" if
+// dir is not in a git repo.
+func MainWorktree(dir string) string {
+	out, err := run(dir, "git", "worktree", "list", "--porcelain")
+	if err != nil {
+		return RepoRoot(dir)
+	}
+	// The main worktree is always listed first, as "worktree <path>".
+	first, _, _ := strings.Cut(out, "\n")
+	path, ok := strings.CutPrefix(first, "worktree ")
+	if !ok {
+		return RepoRoot(dir)
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
 // HeadHash returns the current HEAD commit hash, or "" if there are no
 // commits yet or dir is not a git repo.
 func HeadHash(dir string) string {
@@ -37,6 +66,25 @@ func HeadHash(dir string) string {
 	return out
 }
 
+// Branch returns the name of the checked-out branch, "HEAD" if HEAD is
+// detached (as git reports it), or "" if dir is not a git repo.
+func Branch(dir string) string {
+	if out, err := run(dir, "git", "symbolic-ref", "--short", "-q", "HEAD"); err == nil {
+		return out
+	}
+	if _, err := run(dir, "git", "rev-parse", "--verify", "-q", "HEAD"); err == nil {
+		return "HEAD"
+	}
+	return ""
+}
+
+// IsDirty reports whether the working tree has uncommitted changes,
+// including untracked files. Returns false if dir is not a git repo.
+func IsDirty(dir string) bool {
+	out, err := run(dir, "git", "status", "--porcelain")
+	return err == nil && out != ""
+}
+
 // GetOriginURL returns the URL of the "origin" remote, or "" if none exists.
 func GetOriginURL(dir string) string {
 	out, err := run(dir, "git", "remote", "get-url", "origin")
@@ -46,9 +94,65 @@ func GetOriginURL(dir string) string {
 	return out
 }
 
+// OriginKey returns a canonical key for a remote URL, so that the different
+// ways of spelling one remote compare equal. Remote URLs become
+// "host/owner/repo" in lower case, without the scheme, user, port or ".git"
+// suffix: "git@github.com:Org/Repo.git", "ssh://git@github.com:22/org/repo"
+// and "https://github.com/org/repo/" all give "github.com/org/repo". Local
+// paths are cleaned but otherwise kept as they are. Returns "" for "".
+func OriginKey(url string) string {
+	s := strings.TrimSpace(url)
+	if s == "" {
+		return ""
+	}
+
+	var host, p string
+	if scheme, rest, ok := strings.Cut(s, "://"); ok {
+		if strings.EqualFold(scheme, "file") {
+			return localOriginKey(rest)
+		}
+		host, p, _ = strings.Cut(rest, "/")
+	} else if i := strings.Index(s, ":"); i > 1 && !strings.Contains(s[:i], "/") {
+		// scp-like syntax, [user@]host:path. A single letter before the
+		// colon is a Windows drive, as git treats it.
+		host, p = s[:i], s[i+1:]
+	} else {
+		return localOriginKey(s)
+	}
+
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+
+	p = strings.Trim(p, "/")
+	p = strings.TrimSuffix(p, ".git")
+	p = strings.TrimRight(p, "/")
+	return strings.ToLower(host + "/" + p)
+}
+
+func localOriginKey(p string) string {
+	p = filepath.Clean(p)
+	if base := filepath.Base(p); base == ".git" {
+		p = filepath.Dir(p)
+	}
+	return p
+}
+
 func run(dir string, name string, args ...string) (string, error) {
+	return runEnv(dir, nil, name, args...)
+}
+
+// runEnv is run with extra environment variables.
+func runEnv(dir string, env []string, name string, args ...string) (string, error) {
 	cmd := exec.Command(name, args...)
 	cmd.Dir = dir
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -57,3 +161,272 @@ func run(dir string, name string, args ...string) (string, error) {
 	}
 	return strings.TrimSpace(stdout.String()), nil
 }
+
+// GitPath resolves a path inside the repository's git directory, such as
+// "index" or "hooks", honouring settings like core.hooksPath. Returns "" if
+// dir is not a git repo.
+func GitPath(dir, name string) string {
+	out, err := run(dir, "git", "rev-parse", "--git-path", name)
+	if err != nil {
+		return ""
+	}
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(dir, out)
+	}
+	return out
+}
+
+// CommitTime returns the committer time of rev.
+func CommitTime(dir, rev string) (time.Time, error) {
+	out, err := run(dir, "git", "show", "-s", "--format=%ct", rev)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("git show %s: %w", rev, err)
+	}
+	secs, err := strconv.ParseInt(out, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse commit time %q: %w", out, err)
+	}
+	return time.Unix(secs, 0).UTC(), nil
+}
+
+// ReadNote returns the note attached to rev under refs/notes/<ref>, or "" if
+// there is none.
+func ReadNote(dir, ref, rev string) (string, error) {
+	if _, err := run(dir, "git", "rev-parse", "--verify", "-q", rev+"^{commit}"); err != nil {
+		return "", fmt.Errorf("unknown revision %q", rev)
+	}
+	out, err := run(dir, "git", "notes", "--ref", ref, "show", rev)
+	if err != nil {
+		// git notes show fails when the commit has no note.
+		return "", nil
+	}
+	return out, nil
+}
+
+// WriteNote attaches msg to rev under refs/notes/<ref>, replacing any
+// existing note.
+func WriteNote(dir, ref, rev, msg string) error {
+	if _, err := run(dir, "git", "notes", "--ref", ref, "add", "-f", "-m", msg, rev); err != nil {
+		return fmt.Errorf("git notes add %s: %w", rev, err)
+	}
+	return nil
+}
+
+// NotedCommits returns the hashes of the commits with a note under
+// refs/notes/<ref>.
+func NotedCommits(dir, ref string) (map[string]bool, error) {
+	noted := map[string]bool{}
+	out, err := run(dir, "git", "notes", "--ref", ref, "list")
+	if err != nil {
+		return nil, fmt.Errorf("git notes list: %w", err)
+	}
+	// Each line is "<note blob> <commit>".
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			noted[fields[1]] = true
+		}
+	}
+	return noted, nil
+}
+
+// IndexEntries returns the number of files in the repository's index, read
+// from the index header without running git. ok is false if dir is not a git
+// repo or the index can't be read, e.g. because none has been written yet.
+func IndexEntries(dir string) (n int, ok bool) {
+	indexPath := GitPath(dir, "index")
+	if indexPath == "" {
+		return 0, false
+	}
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	// The header is the "DIRC" signature, a version and the entry count,
+	// each four bytes, big-endian.
+	var header [12]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil || string(header[:4]) != "DIRC" {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint32(header[8:])), true
+}
+
+// SnapshotTree records the current state of the working tree, including
+// uncommitted changes and untracked (but not ignored) files, as a git tree
+// object and returns its hash. The real index is left untouched. Returns ""
+// if dir is not a git repo or the snapshot fails.
+func SnapshotTree(dir string) string {
+	indexPath := GitPath(dir, "index")
+	if indexPath == "" {
+		return ""
+	}
+
+	tmp, err := os.CreateTemp("", "agentstats-index-")
+	if err != nil {
+		return ""
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	// Starting from a copy of the real index lets git skip rehashing files
+	// whose stat info hasn't changed. A repo with no index yet starts empty.
+	if data, err := os.ReadFile(indexPath); err == nil {
+		if err := os.WriteFile(tmp.Name(), data, 0o600); err != nil {
+			return ""
+		}
+	} else {
+		os.Remove(tmp.Name())
+	}
+
+	env := []string{"GIT_INDEX_FILE=" + tmp.Name()}
+	if _, err := runEnv(dir, env, "git", "add", "--all", "--", ":/"); err != nil {
+		return ""
+	}
+	tree, err := runEnv(dir, env, "git", "write-tree")
+	if err != nil {
+		return ""
+	}
+	return tree
+}
+
+// FileStat is the change to one file between two trees. Binary files have
+// zero line counts.
+type FileStat struct {
+	Path    string
+	Added   int
+	Removed int
+	Binary  bool
+}
+
+// DiffStat summarises the changes between two trees.
+type DiffStat struct {
+	Files   []FileStat
+	Added   int
+	Removed int
+}
+
+// Diff returns the per-file line changes from tree-ish from to tree-ish to.
+// Renames are reported as a removal and an addition.
+func Diff(dir, from, to string) (*DiffStat, error) {
+	out, err := run(dir, "git", "diff", "--numstat", "--no-renames", "-z", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("git diff: %w", err)
+	}
+
+	stat := &DiffStat{}
+	for _, rec := range strings.Split(out, "\x00") {
+		if rec == "" {
+			continue
+		}
+		// Each record is "added\tremoved\tpath"; binary files show "-".
+		parts := strings.SplitN(rec, "\t", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("unexpected numstat record %q", rec)
+		}
+		f := FileStat{Path: parts[2]}
+		if parts[0] == "-" {
+			f.Binary = true
+		} else {
+			f.Added, _ = strconv.Atoi(parts[0])
+			f.Removed, _ = strconv.Atoi(parts[1])
+		}
+		stat.Files = append(stat.Files, f)
+		stat.Added += f.Added
+		stat.Removed += f.Removed
+	}
+	return stat, nil
+}
+
+// Commit is a commit with its change totals.
+type Commit struct {
+	Hash        string
+	Subject     string
+	Author      string // "Name <email>"
+	CommittedAt time.Time
+	// ParentCommittedAt is when the commit's first parent was made, or zero
+	// for a root commit.
+	ParentCommittedAt time.Time
+	Files             int
+	Insertions        int
+	Deletions         int
+}
+
+// Commits returns the commits reachable from to but not from, oldest first,
+// as in 'git rev-list from..to'.
+func Commits(dir, from, to string) ([]Commit, error) {
+	return logCommits(dir, from+".."+to)
+}
+
+// CommitsSince returns the commits reachable from rev that were committed
+// at or after since, oldest first.
+func CommitsSince(dir, rev string, since time.Time) ([]Commit, error) {
+	return logCommits(dir, fmt.Sprintf("--since=@%d", since.Unix()), rev)
+}
+
+// logCommits runs git log with args and parses the commits it lists.
+func logCommits(dir string, args ...string) ([]Commit, error) {
+	args = append([]string{"log", "--reverse", "--no-renames", "--numstat",
+		"--format=%x1e%H%x1f%P%x1f%an <%ae>%x1f%ct%x1f%s"}, args...)
+	out, err := run(dir, "git", args...)
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+
+	var commits []Commit
+	var firstParents []string
+	times := map[string]time.Time{}
+	for _, rec := range strings.Split(out, "\x1e") {
+		rec = strings.TrimSpace(rec)
+		if rec == "" {
+			continue
+		}
+		lines := strings.Split(rec, "\n")
+		fields := strings.SplitN(lines[0], "\x1f", 5)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("unexpected git log header %q", lines[0])
+		}
+		secs, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse commit time %q: %w", fields[3], err)
+		}
+		c := Commit{
+			Hash:        fields[0],
+			Author:      fields[2],
+			CommittedAt: time.Unix(secs, 0).UTC(),
+			Subject:     fields[4],
+		}
+		for _, line := range lines[1:] {
+			parts := strings.SplitN(line, "\t", 3)
+			if len(parts) != 3 {
+				continue
+			}
+			c.Files++
+			// Binary files show "-" and count as no lines.
+			added, _ := strconv.Atoi(parts[0])
+			removed, _ := strconv.Atoi(parts[1])
+			c.Insertions += added
+			c.Deletions += removed
+		}
+		commits = append(commits, c)
+		times[c.Hash] = c.CommittedAt
+
+		parent, _, _ := strings.Cut(fields[1], " ")
+		firstParents = append(firstParents, parent)
+	}
+
+	for i, parent := range firstParents {
+		if parent == "" {
+			continue
+		}
+		t, ok := times[parent]
+		if !ok {
+			var err error
+			if t, err = CommitTime(dir, parent); err != nil {
+				return nil, err
+			}
+		}
+		commits[i].ParentCommittedAt = t
+	}
+	return commits, nil
+}
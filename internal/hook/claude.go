@@ -8,12 +8,21 @@ import (
 
 // claudeCodePayload is the JSON structure Claude Code sends to hooks.
 type claudeCodePayload struct {
-	SessionID   string `json:"session_id"`
-	Cwd         string `json:"cwd"`
-	HookEvent   string `json:"hook_event_name"`
-	Prompt      string `json:"prompt"`         // present on UserPromptSubmit
-	Transcript  string `json:"transcript_path"`
-	Permission  string `json:"permission_mode"`
+	SessionID    string          `json:"session_id"`
+	Cwd          string          `json:"cwd"`
+	HookEvent    string          `json:"hook_event_name"`
+	Prompt       string          `json:"prompt"` // present on UserPromptSubmit
+	Transcript   string          `json:"transcript_path"`
+	Permission   string          `json:"permission_mode"`
+	Source       string          `json:"source"`            // present on SessionStart
+	Reason       string          `json:"reason"`            // present on SessionEnd
+	Message      string          `json:"message"`           // present on Notification
+	Notification string          `json:"notification_type"` // present on Notification
+	AgentID      string          `json:"agent_id"`          // present on SubagentStop
+	ToolName     string          `json:"tool_name"`         // present on PreToolUse/PostToolUse
+	ToolUseID    string          `json:"tool_use_id"`       // present on PreToolUse/PostToolUse
+	ToolInput    json.RawMessage `json:"tool_input"`        // present on PreToolUse/PostToolUse
+	ToolResponse json.RawMessage `json:"tool_response"`     // present on PostToolUse
 }
 
 // ClaudeCodeParser implements Parser for Claude Code hooks.
@@ -34,12 +43,25 @@ func (p *ClaudeCodeParser) Parse(r io.Reader, eventType EventType) (*HookInput,
 	if payload.Cwd == "" {
 		return nil, fmt.Errorf("missing cwd in hook payload")
 	}
+	if (eventType == EventToolStart || eventType == EventToolEnd) && payload.ToolName == "" {
+		return nil, fmt.Errorf("missing tool_name in hook payload")
+	}
 
 	return &HookInput{
-		SessionID:  payload.SessionID,
-		Cwd:        payload.Cwd,
-		PromptText: payload.Prompt,
-		AgentType:  "claude-code",
-		EventType:  eventType,
+		SessionID:        payload.SessionID,
+		Cwd:              payload.Cwd,
+		PromptText:       payload.Prompt,
+		AgentType:        "claude-code",
+		EventType:        eventType,
+		TranscriptPath:   payload.Transcript,
+		StartSource:      payload.Source,
+		EndReason:        payload.Reason,
+		NotificationType: payload.Notification,
+		Message:          payload.Message,
+		SubagentID:       payload.AgentID,
+		ToolName:         payload.ToolName,
+		ToolUseID:        payload.ToolUseID,
+		ToolInput:        string(payload.ToolInput),
+		ToolResponse:     string(payload.ToolResponse),
 	}, nil
 }
@@ -61,6 +61,87 @@ func TestClaudeCodeParser_Stop(t *testing.T) {
 	}
 }
 
+func TestClaudeCodeParser_ToolEnd(t *testing.T) {
+	json := `{
+		"session_id": "abc-123",
+		"cwd": "/home/user/myapp",
+		"hook_event_name": "PostToolUse",
+		"tool_name": "Bash",
+		"tool_use_id": "toolu_01",
+		"tool_input": {"command": "go test ./..."},
+		"tool_response": {"stdout": "ok", "exit_code": 0}
+	}`
+
+	p := &hook.ClaudeCodeParser{}
+	input, err := p.Parse(strings.NewReader(json), hook.EventToolEnd)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.ToolName != "Bash" {
+		t.Errorf("ToolName: got %q", input.ToolName)
+	}
+	if input.ToolUseID != "toolu_01" {
+		t.Errorf("ToolUseID: got %q", input.ToolUseID)
+	}
+	if input.ToolInput != `{"command": "go test ./..."}` {
+		t.Errorf("ToolInput: got %q", input.ToolInput)
+	}
+	if input.ToolResponse != `{"stdout": "ok", "exit_code": 0}` {
+		t.Errorf("ToolResponse: got %q", input.ToolResponse)
+	}
+}
+
+func TestClaudeCodeParser_ToolMissingName(t *testing.T) {
+	json := `{"session_id": "abc", "cwd": "/tmp", "hook_event_name": "PreToolUse"}`
+	p := &hook.ClaudeCodeParser{}
+	_, err := p.Parse(strings.NewReader(json), hook.EventToolStart)
+	if err == nil {
+		t.Error("expected error for missing tool_name")
+	}
+}
+
+func TestClaudeCodeParser_SessionEvents(t *testing.T) {
+	json := `{"session_id": "abc-123", "cwd": "/tmp", "hook_event_name": "SessionStart", "source": "resume"}`
+	p := &hook.ClaudeCodeParser{}
+	input, err := p.Parse(strings.NewReader(json), hook.EventSessionStart)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.StartSource != "resume" {
+		t.Errorf("StartSource: got %q", input.StartSource)
+	}
+
+	json = `{"session_id": "abc-123", "cwd": "/tmp", "hook_event_name": "SessionEnd", "reason": "logout"}`
+	input, err = p.Parse(strings.NewReader(json), hook.EventSessionEnd)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.EndReason != "logout" {
+		t.Errorf("EndReason: got %q", input.EndReason)
+	}
+}
+
+func TestClaudeCodeParser_Notification(t *testing.T) {
+	json := `{
+		"session_id": "abc-123",
+		"cwd": "/tmp",
+		"hook_event_name": "Notification",
+		"message": "Claude needs your permission to use Bash",
+		"notification_type": "permission_prompt"
+	}`
+	p := &hook.ClaudeCodeParser{}
+	input, err := p.Parse(strings.NewReader(json), hook.EventNotification)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.NotificationType != "permission_prompt" {
+		t.Errorf("NotificationType: got %q", input.NotificationType)
+	}
+	if input.Message != "Claude needs your permission to use Bash" {
+		t.Errorf("Message: got %q", input.Message)
+	}
+}
+
 func TestClaudeCodeParser_MissingSessionID(t *testing.T) {
 	json := `{"cwd": "/tmp", "hook_event_name": "Stop"}`
 	p := &hook.ClaudeCodeParser{}
@@ -88,6 +169,22 @@ func TestParserForAgent(t *testing.T) {
 		t.Errorf("AgentType: got %q", p.AgentType())
 	}
 
+	p, err = hook.ParserForAgent("codex")
+	if err != nil {
+		t.Fatalf("ParserForAgent(codex): %v", err)
+	}
+	if p.AgentType() != "codex" {
+		t.Errorf("AgentType: got %q", p.AgentType())
+	}
+
+	p, err = hook.ParserForAgent("gemini-cli")
+	if err != nil {
+		t.Fatalf("ParserForAgent(gemini-cli): %v", err)
+	}
+	if p.AgentType() != "gemini-cli" {
+		t.Errorf("AgentType: got %q", p.AgentType())
+	}
+
 	_, err = hook.ParserForAgent("unknown-agent")
 	if err == nil {
 		t.Error("expected error for unknown agent")
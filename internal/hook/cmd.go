@@ -1,17 +1,25 @@
 package hook
 
 import (
+	"database/sql"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/dansimau/agentstats/internal/config"
 	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/project"
 	"github.com/spf13/cobra"
 )
 
 // NewHookCmd returns the 'hook' subcommand (and its children).
 func NewHookCmd() *cobra.Command {
 	var agentType string
+	var mappingPath string
 	var dbPath string
+	var configPath string
 
 	hookCmd := &cobra.Command{
 		Use:   "hook",
@@ -23,8 +31,14 @@ func NewHookCmd() *cobra.Command {
 
 	run := func(eventType EventType) func(cmd *cobra.Command, args []string) {
 		return func(cmd *cobra.Command, args []string) {
+			// Agents send the payload on stdin, except Codex notify, which
+			// passes it as the last argument to turn-complete.
+			var payload io.Reader = os.Stdin
+			if eventType == EventTurnComplete && len(args) > 0 {
+				payload = strings.NewReader(args[len(args)-1])
+			}
 			// Hooks must always exit 0.
-			if err := handleHook(dbPath, agentType, eventType); err != nil {
+			if err := handleHook(dbPath, configPath, agentType, mappingPath, eventType, payload); err != nil {
 				fmt.Fprintln(os.Stderr, "agentstats hook error:", err)
 			}
 		}
@@ -42,17 +56,72 @@ func NewHookCmd() *cobra.Command {
 		Run:   run(EventPromptEnd),
 	}
 
-	hookCmd.PersistentFlags().StringVar(&agentType, "agent", "claude-code", "Agent type (e.g. claude-code)")
+	toolStartCmd := &cobra.Command{
+		Use:   "tool-start",
+		Short: "Record the start of a tool call (PreToolUse event)",
+		Run:   run(EventToolStart),
+	}
+
+	toolEndCmd := &cobra.Command{
+		Use:   "tool-end",
+		Short: "Record the end of a tool call (PostToolUse event)",
+		Run:   run(EventToolEnd),
+	}
+
+	sessionStartCmd := &cobra.Command{
+		Use:   "session-start",
+		Short: "Record the start of a session (SessionStart event)",
+		Run:   run(EventSessionStart),
+	}
+
+	sessionEndCmd := &cobra.Command{
+		Use:   "session-end",
+		Short: "Record the end of a session (SessionEnd event)",
+		Run:   run(EventSessionEnd),
+	}
+
+	notificationCmd := &cobra.Command{
+		Use:   "notification",
+		Short: "Record the agent waiting on the user (Notification event)",
+		Run:   run(EventNotification),
+	}
+
+	subagentStopCmd := &cobra.Command{
+		Use:   "subagent-stop",
+		Short: "Record the end of a subagent run (SubagentStop event)",
+		Run:   run(EventSubagentStop),
+	}
+
+	turnCompleteCmd := &cobra.Command{
+		Use:   "turn-complete [payload]",
+		Short: "Record a whole prompt once it has finished (Codex notify)",
+		Args:  cobra.MaximumNArgs(1),
+		Run:   run(EventTurnComplete),
+	}
+
+	hookCmd.PersistentFlags().StringVar(&agentType, "agent", "claude-code", "Agent type (claude-code, codex, gemini-cli, generic)")
+	hookCmd.PersistentFlags().StringVar(&mappingPath, "mapping", "", "Field mapping file for --agent generic")
 	hookCmd.PersistentFlags().StringVar(&dbPath, "db", "", "Path to database (default: XDG data dir)")
+	hookCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to config file (default: XDG config dir)")
 
-	hookCmd.AddCommand(startCmd, endCmd)
+	hookCmd.AddCommand(
+		startCmd, endCmd,
+		toolStartCmd, toolEndCmd,
+		sessionStartCmd, sessionEndCmd,
+		notificationCmd,
+		subagentStopCmd,
+		turnCompleteCmd,
+	)
 	return hookCmd
 }
 
-func handleHook(dbPath, agentType string, eventType EventType) error {
+func handleHook(dbPath, configPath, agentType, mappingPath string, eventType EventType, payload io.Reader) error {
 	if dbPath == "" {
 		dbPath = db.DefaultPath()
 	}
+	if configPath == "" {
+		configPath = config.DefaultPath()
+	}
 
 	database, err := db.Open(dbPath)
 	if err != nil {
@@ -60,22 +129,68 @@ func handleHook(dbPath, agentType string, eventType EventType) error {
 	}
 	defer database.Close()
 
-	parser, err := ParserForAgent(agentType)
+	parser, err := ParserForAgent(agentType, WithMapping(mappingPath))
 	if err != nil {
 		return err
 	}
 
-	input, err := parser.Parse(os.Stdin, eventType)
+	input, err := parser.Parse(payload, eventType)
 	if err != nil {
 		return fmt.Errorf("parse hook input: %w", err)
 	}
 
-	switch eventType {
+	startsPrompt := input.EventType == EventPromptStart || input.EventType == EventTurnComplete
+
+	var cfg *config.Config
+	if startsPrompt || input.EventType == EventPromptEnd {
+		// A broken config file must not stop prompts being recorded.
+		if cfg, err = config.Load(configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "agentstats hook error: %v; using the default config\n", err)
+			cfg = config.Default()
+		}
+		input.Subproject = project.Subproject(input.Cwd, cfg.Subprojects)
+		input.SkipSnapshot = !cfg.Git.Snapshot(input.Cwd)
+	}
+
+	if err := record(database, input); err != nil {
+		return err
+	}
+
+	// A new prompt is a convenient time to time out prompts abandoned in
+	// other sessions. This runs after recording so that an open prompt in
+	// the same session is closed as interrupted instead.
+	if startsPrompt {
+		if _, err := ReapStale(database, time.Duration(cfg.GC.StaleAfter)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record dispatches input to the recorder for its event type.
+func record(database *sql.DB, input *HookInput) error {
+	// Use the parser's event type: some agents report a different one than
+	// the subcommand implies.
+	switch input.EventType {
 	case EventPromptStart:
 		return RecordPromptStart(database, input)
 	case EventPromptEnd:
 		return RecordPromptEnd(database, input)
+	case EventToolStart:
+		return RecordToolStart(database, input)
+	case EventToolEnd:
+		return RecordToolEnd(database, input)
+	case EventTurnComplete:
+		return RecordTurnComplete(database, input)
+	case EventSessionStart:
+		return RecordSessionStart(database, input)
+	case EventSessionEnd:
+		return RecordSessionEnd(database, input)
+	case EventNotification:
+		return RecordNotification(database, input)
+	case EventSubagentStop:
+		return RecordSubagentStop(database, input)
 	default:
-		return fmt.Errorf("unknown event type %d", eventType)
+		return fmt.Errorf("unknown event type %d", input.EventType)
 	}
 }
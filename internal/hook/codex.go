@@ -0,0 +1,124 @@
+package hook
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// codexPayload is the JSON structure Codex CLI passes to its notify program.
+type codexPayload struct {
+	Type          string   `json:"type"`
+	ThreadID      string   `json:"thread-id"`
+	TurnID        string   `json:"turn-id"`
+	Cwd           string   `json:"cwd"`
+	InputMessages []string `json:"input-messages"`
+}
+
+// CodexParser implements Parser for Codex CLI notify events. Codex only
+// notifies when a turn completes, so every event is EventTurnComplete and the
+// turn's start is recovered from the Codex session rollout file.
+type CodexParser struct{}
+
+func (p *CodexParser) AgentType() string { return "codex" }
+
+func (p *CodexParser) Parse(r io.Reader, eventType EventType) (*HookInput, error) {
+	var payload codexPayload
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode codex payload: %w", err)
+	}
+
+	if payload.Type != "agent-turn-complete" {
+		return nil, fmt.Errorf("unsupported codex notification type %q", payload.Type)
+	}
+	if payload.ThreadID == "" {
+		return nil, fmt.Errorf("missing thread-id in codex payload")
+	}
+	if payload.Cwd == "" {
+		return nil, fmt.Errorf("missing cwd in codex payload")
+	}
+
+	turn := codexTurnStart(payload.ThreadID)
+	return &HookInput{
+		SessionID:   payload.ThreadID,
+		Cwd:         payload.Cwd,
+		PromptText:  strings.Join(payload.InputMessages, "\n"),
+		AgentType:   "codex",
+		EventType:   EventTurnComplete,
+		SubmittedAt: turn.at,
+		HeadStart:   turn.head,
+		BranchStart: turn.branch,
+	}, nil
+}
+
+// codexStart is what the rollout file records about the start of a turn.
+type codexStart struct {
+	at     time.Time // zero if unknown
+	head   string    // HEAD commit; "" if unknown
+	branch string    // "" if unknown
+}
+
+// codexTurnStart returns the start of the latest turn in the rollout file
+// for the thread: the time of the last user message, and for the first turn
+// of a session, the git state Codex recorded when the session started. Later
+// turns' git state isn't recorded. Fields are left empty if the rollout
+// can't be found.
+func codexTurnStart(threadID string) codexStart {
+	home := os.Getenv("CODEX_HOME")
+	if home == "" {
+		userHome, err := os.UserHomeDir()
+		if err != nil {
+			return codexStart{}
+		}
+		home = filepath.Join(userHome, ".codex")
+	}
+
+	// Rollouts are stored as sessions/YYYY/MM/DD/rollout-<timestamp>-<thread-id>.jsonl.
+	matches, _ := filepath.Glob(filepath.Join(home, "sessions", "*", "*", "*", "rollout-*-"+threadID+".jsonl"))
+	if len(matches) == 0 {
+		return codexStart{}
+	}
+	f, err := os.Open(matches[len(matches)-1])
+	if err != nil {
+		return codexStart{}
+	}
+	defer f.Close()
+
+	var start, session codexStart
+	userMessages := 0
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		var line struct {
+			Timestamp time.Time `json:"timestamp"`
+			Type      string    `json:"type"`
+			Payload   struct {
+				Type string `json:"type"`
+				Git  struct {
+					CommitHash string `json:"commit_hash"`
+					Branch     string `json:"branch"`
+				} `json:"git"`
+			} `json:"payload"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			continue
+		}
+		switch {
+		case line.Type == "session_meta":
+			session.head, session.branch = line.Payload.Git.CommitHash, line.Payload.Git.Branch
+		case line.Type == "event_msg" && line.Payload.Type == "user_message":
+			start.at = line.Timestamp
+			userMessages++
+		}
+	}
+	if userMessages == 1 {
+		start.head, start.branch = session.head, session.branch
+	}
+	return start
+}
@@ -0,0 +1,124 @@
+package hook_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dansimau/agentstats/internal/hook"
+)
+
+func TestCodexParser_TurnComplete(t *testing.T) {
+	codexHome := t.TempDir()
+	t.Setenv("CODEX_HOME", codexHome)
+
+	rolloutDir := filepath.Join(codexHome, "sessions", "2025", "06", "01")
+	if err := os.MkdirAll(rolloutDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rollout := `{"timestamp":"2025-06-01T10:00:00.000Z","type":"session_meta","payload":{"id":"thread-1","git":{"commit_hash":"abc123","branch":"main"}}}
+{"timestamp":"2025-06-01T10:00:05.000Z","type":"event_msg","payload":{"type":"user_message","message":"first"}}
+{"timestamp":"2025-06-01T10:03:00.000Z","type":"event_msg","payload":{"type":"agent_message","message":"done"}}
+{"timestamp":"2025-06-01T10:04:00.000Z","type":"event_msg","payload":{"type":"user_message","message":"second"}}
+`
+	if err := os.WriteFile(filepath.Join(rolloutDir, "rollout-2025-06-01T10-00-00-thread-1.jsonl"), []byte(rollout), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	json := `{
+		"type": "agent-turn-complete",
+		"thread-id": "thread-1",
+		"turn-id": "turn-2",
+		"cwd": "/home/user/myapp",
+		"input-messages": ["Rename the package", "and fix imports"],
+		"last-assistant-message": "Done."
+	}`
+
+	p := &hook.CodexParser{}
+	input, err := p.Parse(strings.NewReader(json), hook.EventPromptEnd)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.SessionID != "thread-1" {
+		t.Errorf("SessionID: got %q", input.SessionID)
+	}
+	if input.Cwd != "/home/user/myapp" {
+		t.Errorf("Cwd: got %q", input.Cwd)
+	}
+	if input.PromptText != "Rename the package\nand fix imports" {
+		t.Errorf("PromptText: got %q", input.PromptText)
+	}
+	if input.AgentType != "codex" {
+		t.Errorf("AgentType: got %q", input.AgentType)
+	}
+	if input.EventType != hook.EventTurnComplete {
+		t.Errorf("EventType: got %v", input.EventType)
+	}
+	want := time.Date(2025, 6, 1, 10, 4, 0, 0, time.UTC)
+	if !input.SubmittedAt.Equal(want) {
+		t.Errorf("SubmittedAt: got %v, want %v", input.SubmittedAt, want)
+	}
+	// The session's git state only describes the start of the first turn.
+	if input.HeadStart != "" || input.BranchStart != "" {
+		t.Errorf("start state for a later turn: got head=%q branch=%q", input.HeadStart, input.BranchStart)
+	}
+}
+
+func TestCodexParser_FirstTurnGitState(t *testing.T) {
+	codexHome := t.TempDir()
+	t.Setenv("CODEX_HOME", codexHome)
+
+	rolloutDir := filepath.Join(codexHome, "sessions", "2025", "06", "01")
+	if err := os.MkdirAll(rolloutDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	rollout := `{"timestamp":"2025-06-01T10:00:00.000Z","type":"session_meta","payload":{"id":"thread-2","git":{"commit_hash":"abc123","branch":"main"}}}
+{"timestamp":"2025-06-01T10:00:05.000Z","type":"event_msg","payload":{"type":"user_message","message":"first"}}
+`
+	if err := os.WriteFile(filepath.Join(rolloutDir, "rollout-2025-06-01T10-00-00-thread-2.jsonl"), []byte(rollout), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	json := `{"type": "agent-turn-complete", "thread-id": "thread-2", "cwd": "/tmp", "input-messages": ["first"]}`
+	p := &hook.CodexParser{}
+	input, err := p.Parse(strings.NewReader(json), hook.EventTurnComplete)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.HeadStart != "abc123" || input.BranchStart != "main" {
+		t.Errorf("start state: got head=%q branch=%q, want abc123 main", input.HeadStart, input.BranchStart)
+	}
+}
+
+func TestCodexParser_NoRollout(t *testing.T) {
+	t.Setenv("CODEX_HOME", t.TempDir())
+	json := `{"type": "agent-turn-complete", "thread-id": "t", "cwd": "/tmp", "input-messages": []}`
+	p := &hook.CodexParser{}
+	input, err := p.Parse(strings.NewReader(json), hook.EventTurnComplete)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if !input.SubmittedAt.IsZero() {
+		t.Errorf("SubmittedAt should be zero without a rollout, got %v", input.SubmittedAt)
+	}
+}
+
+func TestCodexParser_UnsupportedType(t *testing.T) {
+	json := `{"type": "approval-requested", "thread-id": "t", "cwd": "/tmp"}`
+	p := &hook.CodexParser{}
+	_, err := p.Parse(strings.NewReader(json), hook.EventTurnComplete)
+	if err == nil {
+		t.Error("expected error for unsupported notification type")
+	}
+}
+
+func TestCodexParser_MissingThreadID(t *testing.T) {
+	json := `{"type": "agent-turn-complete", "cwd": "/tmp"}`
+	p := &hook.CodexParser{}
+	_, err := p.Parse(strings.NewReader(json), hook.EventTurnComplete)
+	if err == nil {
+		t.Error("expected error for missing thread-id")
+	}
+}
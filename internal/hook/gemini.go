@@ -0,0 +1,62 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// geminiCLIPayload is the JSON structure Gemini CLI sends to hooks.
+type geminiCLIPayload struct {
+	SessionID  string `json:"session_id"`
+	Cwd        string `json:"cwd"`
+	HookEvent  string `json:"hook_event_name"`
+	Prompt     string `json:"prompt"` // present on BeforeAgent and AfterAgent
+	Transcript string `json:"transcript_path"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// geminiEvents maps Gemini CLI hook event names onto our event types.
+var geminiEvents = map[string]EventType{
+	"BeforeAgent": EventPromptStart,
+	"AfterAgent":  EventPromptEnd,
+}
+
+// GeminiCLIParser implements Parser for Gemini CLI hooks.
+type GeminiCLIParser struct{}
+
+func (p *GeminiCLIParser) AgentType() string { return "gemini-cli" }
+
+func (p *GeminiCLIParser) Parse(r io.Reader, eventType EventType) (*HookInput, error) {
+	var payload geminiCLIPayload
+	dec := json.NewDecoder(r)
+	if err := dec.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode gemini-cli payload: %w", err)
+	}
+
+	if payload.SessionID == "" {
+		return nil, fmt.Errorf("missing session_id in hook payload")
+	}
+	if payload.Cwd == "" {
+		return nil, fmt.Errorf("missing cwd in hook payload")
+	}
+
+	// The event name in the payload wins over the subcommand, so a
+	// misconfigured hook can't record a start as an end.
+	if et, ok := geminiEvents[payload.HookEvent]; ok {
+		eventType = et
+	}
+
+	// The transcript is in Gemini's own format, which we don't read, so
+	// TranscriptPath is deliberately left empty.
+	input := &HookInput{
+		SessionID: payload.SessionID,
+		Cwd:       payload.Cwd,
+		AgentType: "gemini-cli",
+		EventType: eventType,
+	}
+	if eventType == EventPromptStart {
+		input.PromptText = payload.Prompt
+	}
+	return input, nil
+}
@@ -0,0 +1,93 @@
+package hook_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dansimau/agentstats/internal/hook"
+)
+
+func TestGeminiCLIParser_BeforeAgent(t *testing.T) {
+	json := `{
+		"session_id": "gem-123",
+		"transcript_path": "/home/user/.gemini/tmp/abc/chats/session.json",
+		"cwd": "/home/user/myapp",
+		"hook_event_name": "BeforeAgent",
+		"timestamp": "2025-06-01T10:00:00.000Z",
+		"prompt": "Add authentication"
+	}`
+
+	p := &hook.GeminiCLIParser{}
+	input, err := p.Parse(strings.NewReader(json), hook.EventPromptStart)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.SessionID != "gem-123" {
+		t.Errorf("SessionID: got %q", input.SessionID)
+	}
+	if input.Cwd != "/home/user/myapp" {
+		t.Errorf("Cwd: got %q", input.Cwd)
+	}
+	if input.PromptText != "Add authentication" {
+		t.Errorf("PromptText: got %q", input.PromptText)
+	}
+	if input.AgentType != "gemini-cli" {
+		t.Errorf("AgentType: got %q", input.AgentType)
+	}
+	if input.EventType != hook.EventPromptStart {
+		t.Errorf("EventType: got %v", input.EventType)
+	}
+}
+
+func TestGeminiCLIParser_AfterAgent(t *testing.T) {
+	json := `{
+		"session_id": "gem-123",
+		"cwd": "/home/user/myapp",
+		"hook_event_name": "AfterAgent",
+		"prompt": "Add authentication",
+		"prompt_response": "Done.",
+		"stop_hook_active": false
+	}`
+
+	p := &hook.GeminiCLIParser{}
+	input, err := p.Parse(strings.NewReader(json), hook.EventPromptEnd)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.EventType != hook.EventPromptEnd {
+		t.Errorf("EventType: got %v", input.EventType)
+	}
+	if input.PromptText != "" {
+		t.Errorf("PromptText should be empty for AfterAgent event, got %q", input.PromptText)
+	}
+}
+
+func TestGeminiCLIParser_EventNameWins(t *testing.T) {
+	json := `{"session_id": "gem-123", "cwd": "/tmp", "hook_event_name": "AfterAgent"}`
+	p := &hook.GeminiCLIParser{}
+	input, err := p.Parse(strings.NewReader(json), hook.EventPromptStart)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.EventType != hook.EventPromptEnd {
+		t.Errorf("EventType: got %v, want EventPromptEnd", input.EventType)
+	}
+}
+
+func TestGeminiCLIParser_MissingSessionID(t *testing.T) {
+	json := `{"cwd": "/tmp", "hook_event_name": "AfterAgent"}`
+	p := &hook.GeminiCLIParser{}
+	_, err := p.Parse(strings.NewReader(json), hook.EventPromptEnd)
+	if err == nil {
+		t.Error("expected error for missing session_id")
+	}
+}
+
+func TestGeminiCLIParser_MissingCwd(t *testing.T) {
+	json := `{"session_id": "abc", "hook_event_name": "AfterAgent"}`
+	p := &hook.GeminiCLIParser{}
+	_, err := p.Parse(strings.NewReader(json), hook.EventPromptEnd)
+	if err == nil {
+		t.Error("expected error for missing cwd")
+	}
+}
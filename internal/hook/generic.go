@@ -0,0 +1,128 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Mapping describes how to extract HookInput fields from an arbitrary JSON
+// payload. Paths are dot-separated object keys, with numeric segments
+// indexing into arrays (e.g. "input.messages.0.text").
+type Mapping struct {
+	AgentType string `json:"agent_type"` // recorded agent type (default "generic")
+	SessionID string `json:"session_id"` // path to the session ID (required)
+	Cwd       string `json:"cwd"`        // path to the working directory (required)
+	Prompt    string `json:"prompt"`     // path to the prompt text
+	Event     string `json:"event"`      // path to the event name
+
+	// StartEvents and EndEvents list event names (values at Event) that mark
+	// the start and end of a prompt. Other values fall back to the event
+	// type of the subcommand.
+	StartEvents []string `json:"start_events"`
+	EndEvents   []string `json:"end_events"`
+}
+
+// LoadMapping reads and validates a mapping file.
+func LoadMapping(path string) (*Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read mapping: %w", err)
+	}
+	var m Mapping
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse mapping %s: %w", path, err)
+	}
+	if m.SessionID == "" {
+		return nil, fmt.Errorf("mapping %s: session_id path is required", path)
+	}
+	if m.Cwd == "" {
+		return nil, fmt.Errorf("mapping %s: cwd path is required", path)
+	}
+	if m.AgentType == "" {
+		m.AgentType = "generic"
+	}
+	return &m, nil
+}
+
+// GenericParser implements Parser for any agent whose hook payload is JSON,
+// using a Mapping to locate the fields.
+type GenericParser struct {
+	Mapping *Mapping
+}
+
+func (p *GenericParser) AgentType() string { return p.Mapping.AgentType }
+
+func (p *GenericParser) Parse(r io.Reader, eventType EventType) (*HookInput, error) {
+	var payload interface{}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode %s payload: %w", p.Mapping.AgentType, err)
+	}
+
+	m := p.Mapping
+	sessionID := lookupPath(payload, m.SessionID)
+	if sessionID == "" {
+		return nil, fmt.Errorf("missing %s (session_id) in hook payload", m.SessionID)
+	}
+	cwd := lookupPath(payload, m.Cwd)
+	if cwd == "" {
+		return nil, fmt.Errorf("missing %s (cwd) in hook payload", m.Cwd)
+	}
+
+	if m.Event != "" {
+		event := lookupPath(payload, m.Event)
+		switch {
+		case slices.Contains(m.StartEvents, event):
+			eventType = EventPromptStart
+		case slices.Contains(m.EndEvents, event):
+			eventType = EventPromptEnd
+		}
+	}
+
+	input := &HookInput{
+		SessionID: sessionID,
+		Cwd:       cwd,
+		AgentType: m.AgentType,
+		EventType: eventType,
+	}
+	if eventType == EventPromptStart && m.Prompt != "" {
+		input.PromptText = lookupPath(payload, m.Prompt)
+	}
+	return input, nil
+}
+
+// lookupPath returns the scalar at path in v as a string, or "" if the path
+// doesn't exist or doesn't lead to a scalar.
+func lookupPath(v interface{}, path string) string {
+	for _, seg := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[seg]
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(node) {
+				return ""
+			}
+			v = node[i]
+		default:
+			return ""
+		}
+	}
+
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,120 @@
+package hook_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dansimau/agentstats/internal/hook"
+)
+
+func writeMapping(t *testing.T, json string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mapping.json")
+	if err := os.WriteFile(path, []byte(json), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testMapping = `{
+	"agent_type": "in-house",
+	"session_id": "session.id",
+	"cwd": "workspace.path",
+	"prompt": "input.messages.0.text",
+	"event": "kind",
+	"start_events": ["turn.started"],
+	"end_events": ["turn.finished"]
+}`
+
+func TestGenericParser_Start(t *testing.T) {
+	p, err := hook.ParserForAgent("generic", hook.WithMapping(writeMapping(t, testMapping)))
+	if err != nil {
+		t.Fatalf("ParserForAgent(generic): %v", err)
+	}
+	if p.AgentType() != "in-house" {
+		t.Errorf("AgentType: got %q", p.AgentType())
+	}
+
+	json := `{
+		"kind": "turn.started",
+		"session": {"id": 42},
+		"workspace": {"path": "/home/user/myapp"},
+		"input": {"messages": [{"text": "Add authentication"}, {"text": "ignored"}]}
+	}`
+	input, err := p.Parse(strings.NewReader(json), hook.EventPromptEnd)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.SessionID != "42" {
+		t.Errorf("SessionID: got %q", input.SessionID)
+	}
+	if input.Cwd != "/home/user/myapp" {
+		t.Errorf("Cwd: got %q", input.Cwd)
+	}
+	if input.PromptText != "Add authentication" {
+		t.Errorf("PromptText: got %q", input.PromptText)
+	}
+	if input.AgentType != "in-house" {
+		t.Errorf("AgentType: got %q", input.AgentType)
+	}
+	if input.EventType != hook.EventPromptStart {
+		t.Errorf("EventType: got %v, want EventPromptStart from event name", input.EventType)
+	}
+}
+
+func TestGenericParser_EndAndFallback(t *testing.T) {
+	p, err := hook.ParserForAgent("generic", hook.WithMapping(writeMapping(t, testMapping)))
+	if err != nil {
+		t.Fatalf("ParserForAgent(generic): %v", err)
+	}
+
+	json := `{"kind": "turn.finished", "session": {"id": "s1"}, "workspace": {"path": "/tmp"}}`
+	input, err := p.Parse(strings.NewReader(json), hook.EventPromptStart)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.EventType != hook.EventPromptEnd {
+		t.Errorf("EventType: got %v, want EventPromptEnd", input.EventType)
+	}
+
+	// Unknown event names keep the subcommand's event type.
+	json = `{"kind": "heartbeat", "session": {"id": "s1"}, "workspace": {"path": "/tmp"}}`
+	input, err = p.Parse(strings.NewReader(json), hook.EventPromptEnd)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if input.EventType != hook.EventPromptEnd {
+		t.Errorf("EventType: got %v, want EventPromptEnd", input.EventType)
+	}
+}
+
+func TestGenericParser_MissingFields(t *testing.T) {
+	p, err := hook.ParserForAgent("generic", hook.WithMapping(writeMapping(t, testMapping)))
+	if err != nil {
+		t.Fatalf("ParserForAgent(generic): %v", err)
+	}
+	if _, err := p.Parse(strings.NewReader(`{"workspace": {"path": "/tmp"}}`), hook.EventPromptEnd); err == nil {
+		t.Error("expected error for missing session id")
+	}
+	if _, err := p.Parse(strings.NewReader(`{"session": {"id": "s1"}}`), hook.EventPromptEnd); err == nil {
+		t.Error("expected error for missing cwd")
+	}
+}
+
+func TestGenericParser_BadMapping(t *testing.T) {
+	if _, err := hook.ParserForAgent("generic"); err == nil {
+		t.Error("expected error without a mapping file")
+	}
+	if _, err := hook.ParserForAgent("generic", hook.WithMapping(writeMapping(t, `{"cwd": "cwd"}`))); err == nil {
+		t.Error("expected error for mapping without session_id")
+	}
+	p, err := hook.ParserForAgent("generic", hook.WithMapping(writeMapping(t, `{"session_id": "s", "cwd": "c"}`)))
+	if err != nil {
+		t.Fatalf("minimal mapping: %v", err)
+	}
+	if p.AgentType() != "generic" {
+		t.Errorf("default AgentType: got %q", p.AgentType())
+	}
+}
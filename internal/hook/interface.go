@@ -3,14 +3,29 @@ package hook
 import (
 	"fmt"
 	"io"
+	"time"
 )
 
-// EventType indicates whether the hook is for the start or end of a prompt.
+// EventType identifies which agent lifecycle event a hook invocation is for.
 type EventType int
 
 const (
 	EventPromptStart EventType = iota
 	EventPromptEnd
+	EventToolStart
+	EventToolEnd
+	// EventTurnComplete is a prompt reported only once it has finished, for
+	// agents that don't notify at the start of a turn.
+	EventTurnComplete
+	// EventSessionStart is a session starting, or being resumed or cleared.
+	EventSessionStart
+	// EventSessionEnd is a session ending, e.g. on exit or logout.
+	EventSessionEnd
+	// EventNotification is the agent waiting on the user, e.g. for
+	// permission to run a tool.
+	EventNotification
+	// EventSubagentStop is a subagent spawned by the prompt finishing.
+	EventSubagentStop
 )
 
 // HookInput is the normalized data extracted from a hook event.
@@ -20,6 +35,51 @@ type HookInput struct {
 	PromptText string // empty for prompt-end events
 	AgentType  string
 	EventType  EventType
+
+	// TranscriptPath is the agent's session transcript, if it has one.
+	TranscriptPath string
+
+	// SubmittedAt is when the prompt was submitted, if the agent reports it
+	// after the fact. Zero means now.
+	SubmittedAt time.Time
+
+	// HeadStart and BranchStart are the HEAD commit and branch when the
+	// prompt started, for turn-complete events from agents that record them.
+	// Empty if unknown.
+	HeadStart   string
+	BranchStart string
+
+	// ExitCode is the exit status of the agent process, for prompt-end events
+	// from agents run as a single command. Nil if not applicable.
+	ExitCode *int
+
+	// Subproject is the monorepo sub-project the prompt belongs to, resolved
+	// from Cwd by the caller using the configured rules. Empty for none.
+	Subproject string
+
+	// SkipSnapshot is set by the caller to leave the working tree
+	// unsnapshotted, e.g. because the repo is too large to do it quickly.
+	// The prompt then has no line counts.
+	SkipSnapshot bool
+
+	// StartSource is why a session started (e.g. startup, resume, clear,
+	// compact); EndReason is why it ended (e.g. clear, logout, exit).
+	StartSource string
+	EndReason   string
+
+	// Notification fields are only set for notification events.
+	NotificationType string // e.g. permission_prompt, idle_prompt
+	Message          string
+
+	// SubagentID identifies the subagent for subagent-stop events, if the
+	// agent reports it.
+	SubagentID string
+
+	// Tool fields are only set for tool-start/tool-end events.
+	ToolName     string
+	ToolUseID    string
+	ToolInput    string // raw JSON
+	ToolResponse string // raw JSON; empty for tool-start events
 }
 
 // Parser knows how to read a hook payload for a specific agent type.
@@ -28,12 +88,42 @@ type Parser interface {
 	AgentType() string
 }
 
+// ParserOption configures parsers returned by ParserForAgent.
+type ParserOption func(*parserOptions)
+
+type parserOptions struct {
+	mappingPath string
+}
+
+// WithMapping sets the mapping file used by the generic parser.
+func WithMapping(path string) ParserOption {
+	return func(o *parserOptions) { o.mappingPath = path }
+}
+
 // ParserForAgent returns the Parser for the named agent type.
 // Add new agents by implementing Parser and registering here.
-func ParserForAgent(agentType string) (Parser, error) {
+func ParserForAgent(agentType string, opts ...ParserOption) (Parser, error) {
+	var o parserOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	switch agentType {
 	case "claude-code", "":
 		return &ClaudeCodeParser{}, nil
+	case "codex":
+		return &CodexParser{}, nil
+	case "gemini-cli":
+		return &GeminiCLIParser{}, nil
+	case "generic":
+		if o.mappingPath == "" {
+			return nil, fmt.Errorf("agent type generic requires a mapping file (--mapping)")
+		}
+		m, err := LoadMapping(o.mappingPath)
+		if err != nil {
+			return nil, err
+		}
+		return &GenericParser{Mapping: m}, nil
 	default:
 		return nil, fmt.Errorf("unknown agent type %q", agentType)
 	}
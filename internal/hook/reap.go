@@ -0,0 +1,75 @@
+package hook
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	dbpkg "github.com/dansimau/agentstats/internal/db"
+)
+
+// ReapStale marks prompts that have been in flight for longer than
+// staleAfter as timed out, and returns how many it marked. These are prompts
+// whose end event was lost and that no later prompt in their session closed,
+// e.g. because the agent crashed and the session was never resumed.
+//
+// The end time is the later of the prompt's last recorded activity and the
+// session transcript's last modification, so the duration is capped at when
+// the agent was last seen working rather than running on until now. If
+// neither says anything beyond the submit time, the prompt is left without an
+// end time and so doesn't count towards working time.
+func ReapStale(db *sql.DB, staleAfter time.Duration) (int, error) {
+	now := time.Now()
+	rows, err := db.Query(
+		`SELECT p.id, p.submitted_at, COALESCE(s.transcript_path, '')
+		 FROM prompts p JOIN sessions s ON s.id = p.session_id
+		 WHERE p.status = ? AND p.submitted_at < ?`,
+		dbpkg.StatusInFlight, dbpkg.FormatTime(now.Add(-staleAfter)),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("query stale prompts: %w", err)
+	}
+	type stalePrompt struct {
+		id             string
+		submittedAt    time.Time
+		transcriptPath string
+	}
+	var stale []stalePrompt
+	for rows.Next() {
+		var p stalePrompt
+		if err := rows.Scan(&p.id, &p.submittedAt, &p.transcriptPath); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		stale = append(stale, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, p := range stale {
+		end, err := lastActivity(db, p.id)
+		if err != nil {
+			return 0, err
+		}
+		if p.transcriptPath != "" {
+			if info, err := os.Stat(p.transcriptPath); err == nil && info.ModTime().After(end) && info.ModTime().Before(now) {
+				end = info.ModTime()
+			}
+		}
+
+		var completedAt interface{}
+		if end.After(p.submittedAt) {
+			completedAt = dbpkg.FormatTime(end)
+		}
+		if _, err := db.Exec(
+			`UPDATE prompts SET completed_at = ?, status = ? WHERE id = ? AND status = ?`,
+			completedAt, dbpkg.StatusTimedOut, p.id, dbpkg.StatusInFlight,
+		); err != nil {
+			return 0, fmt.Errorf("time out prompt: %w", err)
+		}
+	}
+	return len(stale), nil
+}
@@ -0,0 +1,88 @@
+package hook_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/hook"
+)
+
+func TestReapStale(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+
+	// The transcript was last written an hour after the prompt started.
+	transcriptPath := filepath.Join(t.TempDir(), "transcript.jsonl")
+	if err := os.WriteFile(transcriptPath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lastWrite := time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(transcriptPath, lastWrite, lastWrite); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range []struct{ sessionID, transcript string }{
+		{"session-stale-transcript", transcriptPath},
+		{"session-stale-bare", ""},
+		{"session-fresh", ""},
+	} {
+		if err := hook.RecordPromptStart(database, &hook.HookInput{
+			SessionID:      s.sessionID,
+			Cwd:            repoDir,
+			PromptText:     "Do the thing",
+			AgentType:      "claude-code",
+			EventType:      hook.EventPromptStart,
+			TranscriptPath: s.transcript,
+		}); err != nil {
+			t.Fatalf("RecordPromptStart: %v", err)
+		}
+	}
+	if _, err := database.Exec(
+		`UPDATE prompts SET submitted_at = '2026-01-01 10:00:00' WHERE session_id LIKE 'session-stale-%'`,
+	); err != nil {
+		t.Fatalf("backdate: %v", err)
+	}
+
+	n, err := hook.ReapStale(database, 6*time.Hour)
+	if err != nil {
+		t.Fatalf("ReapStale: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("ReapStale: got %d, want 2", n)
+	}
+
+	for _, tc := range []struct {
+		sessionID   string
+		status      string
+		completedAt string
+	}{
+		{"session-stale-transcript", db.StatusTimedOut, "2026-01-01 11:00:00"},
+		{"session-stale-bare", db.StatusTimedOut, ""},
+		{"session-fresh", db.StatusInFlight, ""},
+	} {
+		var status, completedAt string
+		if err := database.QueryRow(
+			`SELECT status, COALESCE(completed_at, '') FROM prompts WHERE session_id = ?`, tc.sessionID,
+		).Scan(&status, &completedAt); err != nil {
+			t.Fatalf("%s: %v", tc.sessionID, err)
+		}
+		if status != tc.status || completedAt != tc.completedAt {
+			t.Errorf("%s: got status %q completed_at %q; want %q %q",
+				tc.sessionID, status, completedAt, tc.status, tc.completedAt)
+		}
+	}
+
+	// Already timed-out prompts are not reaped again.
+	if n, err := hook.ReapStale(database, 6*time.Hour); err != nil || n != 0 {
+		t.Errorf("second ReapStale: got %d, %v; want 0", n, err)
+	}
+}
@@ -2,76 +2,686 @@ package hook
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"os"
+	"time"
+	"unicode/utf8"
 
+	dbpkg "github.com/dansimau/agentstats/internal/db"
 	"github.com/dansimau/agentstats/internal/gitx"
 	"github.com/dansimau/agentstats/internal/project"
+	"github.com/dansimau/agentstats/internal/transcript"
 	"github.com/google/uuid"
 )
 
 // RecordPromptStart persists the start of a prompt.
 func RecordPromptStart(db *sql.DB, input *HookInput) error {
+	if err := endWaits(db, input.SessionID); err != nil {
+		return err
+	}
+
 	proj, err := project.Upsert(db, input.Cwd)
 	if err != nil {
 		return fmt.Errorf("upsert project: %w", err)
 	}
 
-	// INSERT OR IGNORE: session may already exist (multiple prompts per session).
+	// The session may already exist (multiple prompts per session).
 	if _, err := db.Exec(
-		`INSERT OR IGNORE INTO sessions (id, project_id, agent_type) VALUES (?, ?, ?)`,
-		input.SessionID, proj.ID, input.AgentType,
+		`INSERT INTO sessions (id, project_id, agent_type, transcript_path) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(id) DO UPDATE SET
+		     transcript_path = COALESCE(excluded.transcript_path, transcript_path)`,
+		input.SessionID, proj.ID, input.AgentType, nullIfEmpty(input.TranscriptPath),
 	); err != nil {
 		return fmt.Errorf("upsert session: %w", err)
 	}
 
-	hashStart := gitx.HeadHash(input.Cwd)
-	var hashVal interface{}
-	if hashStart != "" {
-		hashVal = hashStart
-	}
-
 	promptID := uuid.New().String()
 	var promptText interface{}
 	if input.PromptText != "" {
 		promptText = input.PromptText
 	}
 
+	var submittedAt interface{}
+	if !input.SubmittedAt.IsZero() {
+		submittedAt = dbpkg.FormatTime(input.SubmittedAt)
+	}
+
+	// The prompt is inserted before the slow git work below, so tool calls
+	// reported meanwhile find it open. A turn reported after it finished
+	// can't be inspected at its start, so only what the agent reports about
+	// its start is stored; reports fall back to the end state.
 	if _, err := db.Exec(
-		`INSERT INTO prompts (id, session_id, project_id, prompt_text, submitted_at, git_hash_start, agent_type)
-		 VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, ?, ?)`,
-		promptID, input.SessionID, proj.ID, promptText, hashVal, input.AgentType,
+		`INSERT INTO prompts (
+		     id, session_id, project_id, prompt_text, submitted_at,
+		     git_hash_start, branch_start, worktree, subdir, subproject, agent_type)
+		 VALUES (?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?, ?, ?, ?, ?, ?)`,
+		promptID, input.SessionID, proj.ID, promptText, submittedAt,
+		nullIfEmpty(input.HeadStart), nullIfEmpty(input.BranchStart),
+		nullIfEmpty(gitx.RepoRoot(input.Cwd)), nullIfEmpty(project.Subdir(input.Cwd)), nullIfEmpty(input.Subproject),
+		input.AgentType,
 	); err != nil {
 		return fmt.Errorf("insert prompt: %w", err)
 	}
 
+	var treeStart string
+	if input.EventType != EventTurnComplete {
+		treeStart = snapshot(input)
+		branch, dirty := gitState(input.Cwd)
+		if _, err := db.Exec(
+			`UPDATE prompts SET git_hash_start = ?, tree_start = ?, branch_start = ?, dirty_start = ? WHERE id = ?`,
+			nullIfEmpty(gitx.HeadHash(input.Cwd)), nullIfEmpty(treeStart), branch, dirty, promptID,
+		); err != nil {
+			return fmt.Errorf("update prompt: %w", err)
+		}
+	}
+
+	return interruptOpenPrompts(db, input, promptID, treeStart)
+}
+
+// RecordSessionStart persists the start of a session. A session that is
+// resumed under the same ID keeps its original start and is reopened; if it
+// had ended, a new run starts now, so the time in between isn't counted.
+func RecordSessionStart(db *sql.DB, input *HookInput) error {
+	proj, err := project.Upsert(db, input.Cwd)
+	if err != nil {
+		return fmt.Errorf("upsert project: %w", err)
+	}
+
+	if _, err := db.Exec(
+		`INSERT INTO sessions (id, project_id, agent_type, start_source, transcript_path) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(id) DO UPDATE SET
+		     resumed_at = CASE WHEN ended_at IS NULL THEN resumed_at ELSE CURRENT_TIMESTAMP END,
+		     ended_at = NULL,
+		     end_reason = NULL,
+		     start_source = COALESCE(start_source, excluded.start_source),
+		     transcript_path = COALESCE(excluded.transcript_path, transcript_path)`,
+		input.SessionID, proj.ID, input.AgentType, nullIfEmpty(input.StartSource), nullIfEmpty(input.TranscriptPath),
+	); err != nil {
+		return fmt.Errorf("upsert session: %w", err)
+	}
 	return nil
 }
 
-// RecordPromptEnd marks the most recent open prompt in this session as complete.
+// RecordSessionEnd marks a session as ended, adding the run since it started
+// or was resumed to its length. Sessions we never saw start, or that have
+// already ended, are ignored.
+func RecordSessionEnd(db *sql.DB, input *HookInput) error {
+	if err := endWaits(db, input.SessionID); err != nil {
+		return err
+	}
+
+	// Session times are whole seconds; rounding drops julianday's float error.
+	if _, err := db.Exec(
+		`UPDATE sessions
+		 SET ended_at = CURRENT_TIMESTAMP,
+		     end_reason = ?,
+		     run_seconds = COALESCE(run_seconds, 0) +
+		         ROUND((julianday(CURRENT_TIMESTAMP) - julianday(COALESCE(resumed_at, started_at))) * 86400.0)
+		 WHERE id = ? AND ended_at IS NULL`,
+		nullIfEmpty(input.EndReason), input.SessionID,
+	); err != nil {
+		return fmt.Errorf("update session: %w", err)
+	}
+	return nil
+}
+
+// RecordNotification records that the agent is blocked waiting on the user,
+// e.g. for permission to use a tool. Notifications outside a prompt, such as
+// the idle reminder after a response, are ignored.
+func RecordNotification(db *sql.DB, input *HookInput) error {
+	if input.NotificationType == "auth_success" {
+		return nil
+	}
+
+	promptID, _, err := openPrompt(db, input.SessionID)
+	if err != nil {
+		return err
+	}
+	if promptID == "" {
+		return nil
+	}
+
+	// Repeated notifications while already waiting extend the same wait.
+	if _, err := db.Exec(
+		`INSERT INTO prompt_waits (id, prompt_id, session_id, kind, message, started_at)
+		 SELECT ?, ?, ?, ?, ?, `+nowMillis+`
+		 WHERE NOT EXISTS (
+		     SELECT 1 FROM prompt_waits WHERE prompt_id = ? AND ended_at IS NULL
+		 )`,
+		uuid.New().String(), promptID, input.SessionID,
+		nullIfEmpty(input.NotificationType), nullIfEmpty(input.Message),
+		promptID,
+	); err != nil {
+		return fmt.Errorf("insert wait: %w", err)
+	}
+	return nil
+}
+
+// endWaits closes any open waits in the session. Agents don't report the
+// user answering, so the next event (a tool starting or finishing, the prompt
+// ending, a new prompt) is taken as the point work resumed. A permission
+// request comes after its tool's PreToolUse, so the wait for it ends at the
+// next tool to start, or failing that when the tool itself finishes.
+func endWaits(db *sql.DB, sessionID string) error {
+	if _, err := db.Exec(
+		`UPDATE prompt_waits SET ended_at = `+nowMillis+`
+		 WHERE session_id = ? AND ended_at IS NULL`,
+		sessionID,
+	); err != nil {
+		return fmt.Errorf("end waits: %w", err)
+	}
+	return nil
+}
+
+// interruptOpenPrompts closes any prompts other than promptID still open in
+// the session when promptID starts. An open prompt at this point never got its end event,
+// e.g. because the user pressed Esc or the agent crashed, so it is marked
+// interrupted with a best guess at when work on it stopped. Its changes are
+// taken to be those up to treeEnd, the snapshot for the new prompt.
+func interruptOpenPrompts(db *sql.DB, input *HookInput, promptID, treeEnd string) error {
+	rows, err := db.Query(
+		`SELECT id, COALESCE(prompt_text, '') FROM prompts WHERE session_id = ? AND status = ? AND id != ?`,
+		input.SessionID, dbpkg.StatusInFlight, promptID,
+	)
+	if err != nil {
+		return fmt.Errorf("query open prompts: %w", err)
+	}
+	type openPrompt struct{ id, text string }
+	var open []openPrompt
+	for rows.Next() {
+		var p openPrompt
+		if err := rows.Scan(&p.id, &p.text); err != nil {
+			rows.Close()
+			return err
+		}
+		open = append(open, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(open) == 0 {
+		return nil
+	}
+
+	var turns []transcript.Turn
+	if input.TranscriptPath != "" {
+		// Best effort: without the transcript we fall back to recorded activity.
+		turns, _ = transcript.Read(input.TranscriptPath)
+	}
+
+	for _, p := range open {
+		end, err := lastActivity(db, p.id)
+		if err != nil {
+			return err
+		}
+		if turn := transcript.LastTurn(turns, p.text); turn != nil && turn.CompletedAt.After(end) && turn.CompletedAt.Before(time.Now()) {
+			end = turn.CompletedAt
+		}
+
+		if _, err := db.Exec(
+			`UPDATE prompts SET completed_at = ?, status = ? WHERE id = ?`,
+			dbpkg.FormatTime(end), dbpkg.StatusInterrupted, p.id,
+		); err != nil {
+			return fmt.Errorf("interrupt prompt: %w", err)
+		}
+		if err := recordDiff(db, p.id, input.Cwd, treeEnd); err != nil {
+			fmt.Fprintln(os.Stderr, "agentstats hook: record diff:", err)
+		}
+	}
+	return nil
+}
+
+// lastActivity returns the time of the last recorded activity in a prompt:
+// its latest tool call or subagent event, or its submit time if none.
+func lastActivity(db *sql.DB, promptID string) (time.Time, error) {
+	var last string
+	if err := db.QueryRow(
+		`SELECT MAX(t) FROM (
+		     SELECT submitted_at AS t FROM prompts WHERE id = ?
+		     UNION ALL
+		     SELECT COALESCE(completed_at, started_at) FROM tool_calls WHERE prompt_id = ?
+		     UNION ALL
+		     SELECT COALESCE(completed_at, started_at) FROM subagents WHERE prompt_id = ?
+		 )`,
+		promptID, promptID, promptID,
+	).Scan(&last); err != nil {
+		return time.Time{}, fmt.Errorf("query last activity: %w", err)
+	}
+	return dbpkg.ParseTime(last)
+}
+
+// RecordTurnComplete records a whole prompt from a single event sent when the
+// turn has finished. The start time and git state come from
+// input.SubmittedAt, HeadStart and BranchStart if known.
+func RecordTurnComplete(db *sql.DB, input *HookInput) error {
+	if err := RecordPromptStart(db, input); err != nil {
+		return err
+	}
+	return RecordPromptEnd(db, input)
+}
+
+// RecordPromptEnd marks the most recent open prompt in this session as
+// complete, along with its token usage if the agent has a transcript.
 func RecordPromptEnd(db *sql.DB, input *HookInput) error {
+	if err := endWaits(db, input.SessionID); err != nil {
+		return err
+	}
+
+	promptID, promptText, err := openPrompt(db, input.SessionID)
+	if err != nil {
+		return err
+	}
+	// No open prompt is a silent no-op (Stop fires even with no preceding prompt).
+	if promptID == "" {
+		return nil
+	}
+
 	hashEnd := gitx.HeadHash(input.Cwd)
 	var hashVal interface{}
 	if hashEnd != "" {
 		hashVal = hashEnd
 	}
 
-	result, err := db.Exec(
+	var exitCode interface{}
+	if input.ExitCode != nil {
+		exitCode = *input.ExitCode
+	}
+
+	branch, dirty := gitState(input.Cwd)
+
+	if _, err := db.Exec(
 		`UPDATE prompts
 		 SET completed_at = CURRENT_TIMESTAMP,
-		     git_hash_end = ?
+		     status = ?,
+		     git_hash_end = ?,
+		     branch_end = ?,
+		     dirty_end = ?,
+		     exit_code = ?
+		 WHERE id = ?`,
+		dbpkg.StatusCompleted, hashVal, branch, dirty, exitCode, promptID,
+	); err != nil {
+		return fmt.Errorf("update prompt: %w", err)
+	}
+
+	if input.TranscriptPath != "" {
+		if err := recordUsage(db, promptID, promptText, input.TranscriptPath); err != nil {
+			return fmt.Errorf("record usage: %w", err)
+		}
+	}
+
+	// Git attribution is best effort: a repo git can't read shouldn't lose
+	// the rest of the prompt.
+	if err := recordDiff(db, promptID, input.Cwd, snapshot(input)); err != nil {
+		fmt.Fprintln(os.Stderr, "agentstats hook: record diff:", err)
+	}
+	if err := recordCommits(db, promptID, input.Cwd); err != nil {
+		fmt.Fprintln(os.Stderr, "agentstats hook: record commits:", err)
+	}
+	return nil
+}
+
+// snapshot returns a snapshot of input's working tree, or "" if there is
+// none or the caller asked to skip it.
+func snapshot(input *HookInput) string {
+	if input.SkipSnapshot {
+		return ""
+	}
+	return gitx.SnapshotTree(input.Cwd)
+}
+
+// gitState returns the checked-out branch and whether the working tree is
+// dirty, as values to store. Both are nil if cwd is not a git repo.
+func gitState(cwd string) (branch, dirty interface{}) {
+	name := gitx.Branch(cwd)
+	if name == "" {
+		return nil, nil
+	}
+	return name, gitx.IsDirty(cwd)
+}
+
+// recordDiff stores the lines and files changed by a prompt: the difference
+// between the working tree when it started and treeEnd, in total and per
+// file. Prompts without both snapshots are left without change counts.
+func recordDiff(db *sql.DB, promptID, cwd, treeEnd string) error {
+	var treeStart sql.NullString
+	if err := db.QueryRow(`SELECT tree_start FROM prompts WHERE id = ?`, promptID).Scan(&treeStart); err != nil {
+		return err
+	}
+	if !treeStart.Valid || treeEnd == "" {
+		return nil
+	}
+
+	stat, err := gitx.Diff(cwd, treeStart.String, treeEnd)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`UPDATE prompts
+		 SET tree_end = ?, lines_added = ?, lines_removed = ?, files_changed = ?
+		 WHERE id = ?`,
+		treeEnd, stat.Added, stat.Removed, len(stat.Files), promptID,
+	); err != nil {
+		return err
+	}
+	for _, f := range stat.Files {
+		if _, err := tx.Exec(
+			`INSERT OR REPLACE INTO prompt_files (prompt_id, path, lines_added, lines_removed) VALUES (?, ?, ?, ?)`,
+			promptID, f.Path, f.Added, f.Removed,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// recordCommits stores the commits made during a prompt: those between the
+// HEADs recorded at its start and end that were committed after it started.
+// Commits brought in by a pull or merge during the prompt are mostly older
+// and so excluded, but ones committed elsewhere while it ran are counted.
+func recordCommits(db *sql.DB, promptID, cwd string) error {
+	var hashStart, hashEnd sql.NullString
+	var submittedAt string
+	if err := db.QueryRow(
+		`SELECT git_hash_start, git_hash_end, strftime('%Y-%m-%d %H:%M:%S', submitted_at)
+		 FROM prompts WHERE id = ?`, promptID,
+	).Scan(&hashStart, &hashEnd, &submittedAt); err != nil {
+		return err
+	}
+	if !hashStart.Valid || !hashEnd.Valid || hashStart.String == hashEnd.String {
+		return nil
+	}
+	started, err := dbpkg.ParseTime(submittedAt)
+	if err != nil {
+		return err
+	}
+
+	commits, err := gitx.Commits(cwd, hashStart.String, hashEnd.String)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, c := range commits {
+		if c.CommittedAt.Before(started) {
+			continue
+		}
+		var parentAt interface{}
+		if !c.ParentCommittedAt.IsZero() {
+			parentAt = dbpkg.FormatTime(c.ParentCommittedAt)
+		}
+		if _, err := tx.Exec(
+			`INSERT OR REPLACE INTO prompt_commits (
+			     prompt_id, hash, subject, author, committed_at, parent_committed_at,
+			     files_changed, insertions, deletions)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			promptID, c.Hash, c.Subject, c.Author, dbpkg.FormatTime(c.CommittedAt), parentAt,
+			c.Files, c.Insertions, c.Deletions,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// recordUsage stores the model and token counts for a prompt, taken from the
+// matching turn in the transcript.
+func recordUsage(db *sql.DB, promptID, promptText, transcriptPath string) error {
+	turns, err := transcript.Read(transcriptPath)
+	if err != nil {
+		return err
+	}
+	turn := transcript.LastTurn(turns, promptText)
+	if turn == nil {
+		return nil
+	}
+
+	if _, err := db.Exec(
+		`UPDATE prompts
+		 SET model = ?,
+		     input_tokens = ?,
+		     output_tokens = ?,
+		     cache_read_tokens = ?,
+		     cache_write_tokens = ?
+		 WHERE id = ?`,
+		nullIfEmpty(turn.Model),
+		turn.Usage.InputTokens,
+		turn.Usage.OutputTokens,
+		turn.Usage.CacheReadTokens,
+		turn.Usage.CacheWriteTokens,
+		promptID,
+	); err != nil {
+		return fmt.Errorf("update prompt usage: %w", err)
+	}
+	return nil
+}
+
+// nowMillis is the SQL expression for the current UTC time with millisecond
+// precision. Tool calls are often sub-second, so CURRENT_TIMESTAMP is too coarse.
+const nowMillis = `strftime('%Y-%m-%d %H:%M:%f', 'now')`
+
+// RecordToolStart persists the start of a tool call, linked to the open
+// prompt in the session. Tool calls outside of a prompt are ignored.
+func RecordToolStart(db *sql.DB, input *HookInput) error {
+	if err := endWaits(db, input.SessionID); err != nil {
+		return err
+	}
+
+	promptID, _, err := openPrompt(db, input.SessionID)
+	if err != nil {
+		return err
+	}
+	if promptID == "" {
+		return nil
+	}
+
+	id := input.ToolUseID
+	if id == "" {
+		id = uuid.New().String()
+	}
+
+	// Hooks run async, so the matching tool-end may have been recorded first.
+	if _, err := db.Exec(
+		`INSERT INTO tool_calls (id, prompt_id, session_id, tool_name, tool_input, started_at)
+		 VALUES (?, ?, ?, ?, ?, `+nowMillis+`)
+		 ON CONFLICT(id) DO UPDATE SET
+		     tool_input = excluded.tool_input,
+		     started_at = MIN(started_at, excluded.started_at)`,
+		id, promptID, input.SessionID, input.ToolName, toolText(input.ToolInput),
+	); err != nil {
+		return fmt.Errorf("insert tool call: %w", err)
+	}
+
+	if input.ToolName == subagentTool {
+		return recordSubagentStart(db, promptID, input)
+	}
+	return nil
+}
+
+// RecordToolEnd marks a tool call as complete. Calls are matched by tool use
+// ID when the agent provides one, otherwise by the most recent open call to
+// the same tool in the session.
+func RecordToolEnd(db *sql.DB, input *HookInput) error {
+	if err := endWaits(db, input.SessionID); err != nil {
+		return err
+	}
+
+	// The Task tool returns when its subagent finishes. This is more precise
+	// than SubagentStop, which doesn't say which subagent stopped.
+	if input.ToolName == subagentTool && input.ToolUseID != "" {
+		if _, err := db.Exec(
+			`UPDATE subagents SET completed_at = `+nowMillis+` WHERE tool_use_id = ?`,
+			input.ToolUseID,
+		); err != nil {
+			return fmt.Errorf("update subagent: %w", err)
+		}
+	}
+
+	if input.ToolUseID == "" {
+		if _, err := db.Exec(
+			`UPDATE tool_calls
+			 SET completed_at = `+nowMillis+`,
+			     tool_response = ?
+			 WHERE id = (
+			     SELECT id FROM tool_calls
+			     WHERE session_id = ? AND tool_name = ? AND completed_at IS NULL
+			     ORDER BY started_at DESC
+			     LIMIT 1
+			 )`,
+			toolText(input.ToolResponse), input.SessionID, input.ToolName,
+		); err != nil {
+			return fmt.Errorf("update tool call: %w", err)
+		}
+		return nil
+	}
+
+	result, err := db.Exec(
+		`UPDATE tool_calls
+		 SET completed_at = `+nowMillis+`,
+		     tool_response = ?
+		 WHERE id = ?`,
+		toolText(input.ToolResponse), input.ToolUseID,
+	)
+	if err != nil {
+		return fmt.Errorf("update tool call: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	// tool-start hasn't been recorded yet; insert the call and let tool-start
+	// fill in its start time.
+	promptID, _, err := openPrompt(db, input.SessionID)
+	if err != nil {
+		return err
+	}
+	if promptID == "" {
+		return nil
+	}
+	if _, err := db.Exec(
+		`INSERT INTO tool_calls (id, prompt_id, session_id, tool_name, tool_input, tool_response, started_at, completed_at)
+		 VALUES (?, ?, ?, ?, ?, ?, `+nowMillis+`, `+nowMillis+`)
+		 ON CONFLICT(id) DO UPDATE SET
+		     tool_response = excluded.tool_response,
+		     completed_at = excluded.completed_at`,
+		input.ToolUseID, promptID, input.SessionID, input.ToolName,
+		toolText(input.ToolInput), toolText(input.ToolResponse),
+	); err != nil {
+		return fmt.Errorf("insert tool call: %w", err)
+	}
+	return nil
+}
+
+// subagentTool is the Claude Code tool that spawns a subagent.
+const subagentTool = "Task"
+
+// recordSubagentStart records a subagent run spawned by a Task tool call.
+// The run takes its times from the tool call, so a tool-end recorded first
+// leaves it already complete.
+func recordSubagentStart(db *sql.DB, promptID string, input *HookInput) error {
+	var task struct {
+		SubagentType string `json:"subagent_type"`
+		Description  string `json:"description"`
+	}
+	if input.ToolInput != "" {
+		// Best effort: the run is still worth recording without these.
+		_ = json.Unmarshal([]byte(input.ToolInput), &task)
+	}
+
+	if _, err := db.Exec(
+		`INSERT INTO subagents (id, prompt_id, session_id, tool_use_id, subagent_type, description, started_at, completed_at)
+		 VALUES (?1, ?2, ?3, ?4, ?5, ?6,
+		     COALESCE((SELECT started_at FROM tool_calls WHERE id = ?4), `+nowMillis+`),
+		     (SELECT completed_at FROM tool_calls WHERE id = ?4))`,
+		uuid.New().String(), promptID, input.SessionID, nullIfEmpty(input.ToolUseID),
+		nullIfEmpty(task.SubagentType), nullIfEmpty(task.Description),
+	); err != nil {
+		return fmt.Errorf("insert subagent: %w", err)
+	}
+	return nil
+}
+
+// RecordSubagentStop marks a subagent run as complete. SubagentStop doesn't
+// identify which Task call it belongs to, so the oldest open run in the
+// session is closed; the Task tool-end later corrects the time if needed.
+func RecordSubagentStop(db *sql.DB, input *HookInput) error {
+	if err := endWaits(db, input.SessionID); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(
+		`UPDATE subagents
+		 SET completed_at = `+nowMillis+`,
+		     agent_id = COALESCE(?, agent_id)
 		 WHERE id = (
-		     SELECT id FROM prompts
+		     SELECT id FROM subagents
 		     WHERE session_id = ? AND completed_at IS NULL
-		     ORDER BY submitted_at DESC
+		     ORDER BY started_at
 		     LIMIT 1
 		 )`,
-		hashVal, input.SessionID,
-	)
+		nullIfEmpty(input.SubagentID), input.SessionID,
+	); err != nil {
+		return fmt.Errorf("update subagent: %w", err)
+	}
+	return nil
+}
+
+// openPrompt returns the ID and text of the most recent open prompt in the
+// session. The ID is "" if there is none. Ties on the submit second go to the
+// prompt inserted last, which matters while a new prompt is starting and the
+// one before it isn't yet interrupted.
+func openPrompt(db *sql.DB, sessionID string) (id, text string, err error) {
+	err = db.QueryRow(
+		`SELECT id, COALESCE(prompt_text, '') FROM prompts
+		 WHERE session_id = ? AND status = ?
+		 ORDER BY submitted_at DESC, rowid DESC
+		 LIMIT 1`,
+		sessionID, dbpkg.StatusInFlight,
+	).Scan(&id, &text)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
 	if err != nil {
-		return fmt.Errorf("update prompt: %w", err)
+		return "", "", fmt.Errorf("query open prompt: %w", err)
+	}
+	return id, text, nil
+}
+
+// maxToolText is the most bytes of a tool call's input or response that are
+// stored. They can hold whole files or command output, and only the start is
+// needed to tell what the call did.
+const maxToolText = 4096
+
+// toolText returns a tool call's raw input or response as a value to store,
+// cut to maxToolText bytes. The cut JSON may no longer parse.
+func toolText(s string) interface{} {
+	if len(s) > maxToolText {
+		// Cut before a rune start so no character is split.
+		n := maxToolText
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s = s[:n]
 	}
+	return nullIfEmpty(s)
+}
 
-	// 0 rows affected is a silent no-op (Stop fires even with no preceding prompt).
-	_ = result
-	return nil
+// nullIfEmpty maps "" to SQL NULL.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
 }
@@ -1,10 +1,15 @@
 package hook_test
 
 import (
+	"database/sql"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/dansimau/agentstats/internal/db"
 	"github.com/dansimau/agentstats/internal/hook"
@@ -118,3 +123,1076 @@ func TestPromptEnd_NoOp(t *testing.T) {
 		t.Errorf("RecordPromptEnd with no preceding prompt should not error: %v", err)
 	}
 }
+
+func TestPromptStart_InterruptsOpenPrompt(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	sessionID := "session-interrupt-001"
+
+	start := func(text string) {
+		t.Helper()
+		if err := hook.RecordPromptStart(database, &hook.HookInput{
+			SessionID:  sessionID,
+			Cwd:        repoDir,
+			PromptText: text,
+			AgentType:  "claude-code",
+			EventType:  hook.EventPromptStart,
+		}); err != nil {
+			t.Fatalf("RecordPromptStart: %v", err)
+		}
+	}
+
+	start("Refactor the parser")
+	bash := &hook.HookInput{
+		SessionID: sessionID,
+		Cwd:       repoDir,
+		AgentType: "claude-code",
+		EventType: hook.EventToolStart,
+		ToolName:  "Bash",
+		ToolUseID: "toolu_interrupted",
+	}
+	if err := hook.RecordToolStart(database, bash); err != nil {
+		t.Fatalf("RecordToolStart: %v", err)
+	}
+	// Backdate the prompt so the best-guess end is distinguishable from now.
+	if _, err := database.Exec(`
+		UPDATE prompts SET submitted_at = '2026-01-01 10:00:00' WHERE session_id = ?;
+		UPDATE tool_calls SET started_at = '2026-01-01 10:00:30' WHERE id = 'toolu_interrupted';
+	`, sessionID); err != nil {
+		t.Fatalf("backdate: %v", err)
+	}
+
+	// The user pressed Esc, so no prompt-end; the next prompt closes it.
+	start("Just fix the test instead")
+
+	rows, err := database.Query(`
+		SELECT prompt_text, status, COALESCE(completed_at, '')
+		FROM prompts WHERE session_id = ? ORDER BY submitted_at`, sessionID)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	defer rows.Close()
+
+	type result struct{ text, status, completedAt string }
+	var got []result
+	for rows.Next() {
+		var r result
+		if err := rows.Scan(&r.text, &r.status, &r.completedAt); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 prompts, got %d", len(got))
+	}
+	if got[0].status != db.StatusInterrupted || got[0].completedAt != "2026-01-01 10:00:30" {
+		t.Errorf("first prompt: got status %q completed_at %q; want interrupted at last tool call",
+			got[0].status, got[0].completedAt)
+	}
+	if got[1].status != db.StatusInFlight || got[1].completedAt != "" {
+		t.Errorf("second prompt: got status %q completed_at %q; want in-flight", got[1].status, got[1].completedAt)
+	}
+
+	if err := hook.RecordPromptEnd(database, &hook.HookInput{
+		SessionID: sessionID,
+		Cwd:       repoDir,
+		AgentType: "claude-code",
+		EventType: hook.EventPromptEnd,
+	}); err != nil {
+		t.Fatalf("RecordPromptEnd: %v", err)
+	}
+	var status string
+	if err := database.QueryRow(
+		`SELECT status FROM prompts WHERE session_id = ? AND prompt_text = 'Just fix the test instead'`, sessionID,
+	).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status != db.StatusCompleted {
+		t.Errorf("second prompt after end: got status %q, want completed", status)
+	}
+}
+
+func TestToolStart_WhilePromptStarting(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	base := hook.HookInput{SessionID: "session-starting", Cwd: repoDir, AgentType: "claude-code"}
+
+	first := base
+	first.PromptText = "Refactor the parser"
+	first.EventType = hook.EventPromptStart
+	if err := hook.RecordPromptStart(database, &first); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+
+	// The next prompt's row is in but the first isn't interrupted yet, as
+	// while RecordPromptStart snapshots the tree, in the same second.
+	if _, err := database.Exec(`
+		INSERT INTO prompts (id, session_id, project_id, prompt_text, submitted_at, agent_type)
+		SELECT 'prompt-next', session_id, project_id, 'Just fix the test', submitted_at, agent_type
+		FROM prompts WHERE session_id = 'session-starting'`,
+	); err != nil {
+		t.Fatalf("insert next prompt: %v", err)
+	}
+
+	call := base
+	call.EventType = hook.EventToolStart
+	call.ToolName = "Bash"
+	call.ToolUseID = "toolu_early"
+	if err := hook.RecordToolStart(database, &call); err != nil {
+		t.Fatalf("RecordToolStart: %v", err)
+	}
+
+	var promptID string
+	if err := database.QueryRow(`SELECT prompt_id FROM tool_calls WHERE id = 'toolu_early'`).Scan(&promptID); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if promptID != "prompt-next" {
+		t.Errorf("tool call attached to %q, want the starting prompt", promptID)
+	}
+}
+
+func TestPromptEnd_RecordsDiff(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	sessionID := "session-diff-001"
+
+	// Edits made before the prompt aren't attributed to it.
+	if err := os.WriteFile(filepath.Join(repoDir, "f.txt"), []byte("x\ny\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := &hook.HookInput{
+		SessionID:  sessionID,
+		Cwd:        repoDir,
+		PromptText: "Add a new file",
+		AgentType:  "claude-code",
+		EventType:  hook.EventPromptStart,
+	}
+	if err := hook.RecordPromptStart(database, input); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(repoDir, "new.go"), []byte("package a\n\nfunc A() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "f.txt"), []byte("x\nz\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	input.EventType = hook.EventPromptEnd
+	if err := hook.RecordPromptEnd(database, input); err != nil {
+		t.Fatalf("RecordPromptEnd: %v", err)
+	}
+
+	var added, removed, files int
+	if err := database.QueryRow(
+		`SELECT lines_added, lines_removed, files_changed FROM prompts WHERE session_id = ?`, sessionID,
+	).Scan(&added, &removed, &files); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if added != 4 || removed != 1 || files != 2 {
+		t.Errorf("got +%d -%d in %d files; want +4 -1 in 2 files", added, removed, files)
+	}
+
+	rows, err := database.Query(`
+		SELECT f.path, f.lines_added, f.lines_removed
+		FROM prompt_files f JOIN prompts p ON p.id = f.prompt_id
+		WHERE p.session_id = ?
+		ORDER BY f.path`, sessionID)
+	if err != nil {
+		t.Fatalf("query files: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var path string
+		var a, r int
+		if err := rows.Scan(&path, &a, &r); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%s +%d -%d", path, a, r))
+	}
+	want := []string{"f.txt +1 -1", "new.go +3 -0"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("prompt_files: got %v, want %v", got, want)
+	}
+
+	var branchStart, branchEnd string
+	var dirtyStart, dirtyEnd bool
+	if err := database.QueryRow(
+		`SELECT branch_start, branch_end, dirty_start, dirty_end FROM prompts WHERE session_id = ?`, sessionID,
+	).Scan(&branchStart, &branchEnd, &dirtyStart, &dirtyEnd); err != nil {
+		t.Fatalf("query git state: %v", err)
+	}
+	if branchStart == "" || branchStart != branchEnd || !dirtyStart || !dirtyEnd {
+		t.Errorf("git state: got %q/%q dirty %v/%v; want same branch, dirty at both ends",
+			branchStart, branchEnd, dirtyStart, dirtyEnd)
+	}
+}
+
+func TestPromptEnd_SkipSnapshot(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	sessionID := "session-diff-002"
+
+	input := &hook.HookInput{
+		SessionID:    sessionID,
+		Cwd:          repoDir,
+		PromptText:   "Edit a large repo",
+		AgentType:    "claude-code",
+		EventType:    hook.EventPromptStart,
+		SkipSnapshot: true,
+	}
+	if err := hook.RecordPromptStart(database, input); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "f.txt"), []byte("x\ny\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	input.EventType = hook.EventPromptEnd
+	if err := hook.RecordPromptEnd(database, input); err != nil {
+		t.Fatalf("RecordPromptEnd: %v", err)
+	}
+
+	var treeStart, filesChanged sql.NullString
+	var dirtyEnd bool
+	if err := database.QueryRow(
+		`SELECT tree_start, files_changed, dirty_end FROM prompts WHERE session_id = ?`, sessionID,
+	).Scan(&treeStart, &filesChanged, &dirtyEnd); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if treeStart.Valid || filesChanged.Valid {
+		t.Errorf("got tree_start %v, files_changed %v; want both NULL", treeStart, filesChanged)
+	}
+	if !dirtyEnd {
+		t.Error("git state should still be recorded without snapshots")
+	}
+}
+
+func TestPromptEnd_RecordsCommits(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	sessionID := "session-commits-001"
+
+	input := &hook.HookInput{
+		SessionID:  sessionID,
+		Cwd:        repoDir,
+		PromptText: "Fix and commit",
+		AgentType:  "claude-code",
+		EventType:  hook.EventPromptStart,
+	}
+	if err := hook.RecordPromptStart(database, input); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(repoDir, "f.txt"), []byte("x\ny\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"add", "."}, {"commit", "-q", "-m", "Fix f"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	input.EventType = hook.EventPromptEnd
+	if err := hook.RecordPromptEnd(database, input); err != nil {
+		t.Fatalf("RecordPromptEnd: %v", err)
+	}
+
+	var subject string
+	var files, insertions, deletions int
+	var hasParent bool
+	if err := database.QueryRow(`
+		SELECT c.subject, c.files_changed, c.insertions, c.deletions, c.parent_committed_at IS NOT NULL
+		FROM prompt_commits c JOIN prompts p ON p.id = c.prompt_id
+		WHERE p.session_id = ?`, sessionID,
+	).Scan(&subject, &files, &insertions, &deletions, &hasParent); err != nil {
+		t.Fatalf("query commits: %v", err)
+	}
+	if subject != "Fix f" || files != 1 || insertions != 2 || deletions != 1 || !hasParent {
+		t.Errorf("got %q %d files +%d -%d parent %v; want \"Fix f\" 1 files +2 -1 with parent",
+			subject, files, insertions, deletions, hasParent)
+	}
+}
+
+func TestPromptEnd_SkipsPulledCommits(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	sessionID := "session-pulled-001"
+	git := func(env []string, args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), env...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	// A commit made upstream before the prompt, on a branch merged in during it.
+	old := []string{"GIT_AUTHOR_DATE=2020-01-01T00:00:00Z", "GIT_COMMITTER_DATE=2020-01-01T00:00:00Z"}
+	git(nil, "checkout", "-q", "-b", "upstream")
+	git(old, "commit", "-q", "--allow-empty", "-m", "Upstream change")
+	git(nil, "checkout", "-q", "-")
+
+	input := &hook.HookInput{
+		SessionID:  sessionID,
+		Cwd:        repoDir,
+		PromptText: "Pull and fix",
+		AgentType:  "claude-code",
+		EventType:  hook.EventPromptStart,
+	}
+	if err := hook.RecordPromptStart(database, input); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+
+	git(nil, "merge", "-q", "--ff-only", "upstream")
+	git(nil, "commit", "-q", "--allow-empty", "-m", "Fix f")
+
+	input.EventType = hook.EventPromptEnd
+	if err := hook.RecordPromptEnd(database, input); err != nil {
+		t.Fatalf("RecordPromptEnd: %v", err)
+	}
+
+	rows, err := database.Query(`
+		SELECT c.subject FROM prompt_commits c JOIN prompts p ON p.id = c.prompt_id
+		WHERE p.session_id = ?`, sessionID)
+	if err != nil {
+		t.Fatalf("query commits: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var subject string
+		if err := rows.Scan(&subject); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, subject)
+	}
+	if strings.Join(got, ", ") != "Fix f" {
+		t.Errorf("commits: got %v, want [Fix f]", got)
+	}
+}
+
+func TestToolCalls(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	sessionID := "session-tools-001"
+
+	// Tool calls outside a prompt are ignored.
+	orphan := &hook.HookInput{
+		SessionID: sessionID,
+		Cwd:       repoDir,
+		AgentType: "claude-code",
+		EventType: hook.EventToolStart,
+		ToolName:  "Read",
+		ToolUseID: "toolu_orphan",
+	}
+	if err := hook.RecordToolStart(database, orphan); err != nil {
+		t.Fatalf("RecordToolStart without prompt: %v", err)
+	}
+
+	if err := hook.RecordPromptStart(database, &hook.HookInput{
+		SessionID:  sessionID,
+		Cwd:        repoDir,
+		PromptText: "Run the tests",
+		AgentType:  "claude-code",
+		EventType:  hook.EventPromptStart,
+	}); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+
+	bash := &hook.HookInput{
+		SessionID: sessionID,
+		Cwd:       repoDir,
+		AgentType: "claude-code",
+		ToolName:  "Bash",
+		ToolUseID: "toolu_bash",
+		ToolInput: `{"command":"go test ./..."}`,
+	}
+	bash.EventType = hook.EventToolStart
+	if err := hook.RecordToolStart(database, bash); err != nil {
+		t.Fatalf("RecordToolStart: %v", err)
+	}
+	bash.EventType = hook.EventToolEnd
+	bash.ToolResponse = `{"stdout":"ok"}`
+	if err := hook.RecordToolEnd(database, bash); err != nil {
+		t.Fatalf("RecordToolEnd: %v", err)
+	}
+
+	// tool-end arriving before tool-start (async hooks) must not duplicate.
+	grep := &hook.HookInput{
+		SessionID: sessionID,
+		Cwd:       repoDir,
+		AgentType: "claude-code",
+		ToolName:  "Grep",
+		ToolUseID: "toolu_grep",
+	}
+	grep.EventType = hook.EventToolEnd
+	if err := hook.RecordToolEnd(database, grep); err != nil {
+		t.Fatalf("RecordToolEnd before start: %v", err)
+	}
+	grep.EventType = hook.EventToolStart
+	if err := hook.RecordToolStart(database, grep); err != nil {
+		t.Fatalf("RecordToolStart after end: %v", err)
+	}
+
+	var total, completed, linked int
+	row := database.QueryRow(`
+		SELECT COUNT(*), COUNT(t.completed_at), COUNT(p.id)
+		FROM tool_calls t LEFT JOIN prompts p ON p.id = t.prompt_id
+		WHERE t.session_id = ?`, sessionID)
+	if err := row.Scan(&total, &completed, &linked); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if total != 2 || completed != 2 || linked != 2 {
+		t.Errorf("expected 2 completed, linked tool calls; got total=%d completed=%d linked=%d",
+			total, completed, linked)
+	}
+
+	var response string
+	if err := database.QueryRow(
+		`SELECT tool_response FROM tool_calls WHERE id = 'toolu_bash'`,
+	).Scan(&response); err != nil {
+		t.Fatalf("query response: %v", err)
+	}
+	if response != `{"stdout":"ok"}` {
+		t.Errorf("tool_response: got %q", response)
+	}
+}
+
+func TestToolCalls_CapsStoredText(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	base := hook.HookInput{SessionID: "session-tools-cap", Cwd: repoDir, AgentType: "claude-code"}
+
+	start := base
+	start.EventType = hook.EventPromptStart
+	if err := hook.RecordPromptStart(database, &start); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+
+	// A large file read, with multi-byte characters straddling the cap.
+	call := base
+	call.ToolName = "Read"
+	call.ToolUseID = "toolu_big"
+	call.ToolInput = `{"file_path":"big.txt"}`
+	call.EventType = hook.EventToolStart
+	if err := hook.RecordToolStart(database, &call); err != nil {
+		t.Fatalf("RecordToolStart: %v", err)
+	}
+	call.ToolResponse = `{"content":"` + strings.Repeat("é", 100_000) + `"}`
+	call.EventType = hook.EventToolEnd
+	if err := hook.RecordToolEnd(database, &call); err != nil {
+		t.Fatalf("RecordToolEnd: %v", err)
+	}
+
+	var input, response string
+	if err := database.QueryRow(
+		`SELECT tool_input, tool_response FROM tool_calls WHERE id = 'toolu_big'`,
+	).Scan(&input, &response); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if input != call.ToolInput {
+		t.Errorf("small input should be kept whole, got %q", input)
+	}
+	if len(response) > 4096 || len(response) < 4000 || !utf8.ValidString(response) {
+		t.Errorf("response: got %d bytes (valid UTF-8 %v), want it cut to at most 4096", len(response), utf8.ValidString(response))
+	}
+}
+
+func TestPromptEnd_RecordsUsage(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	sessionID := "session-usage-001"
+
+	transcriptPath := filepath.Join(t.TempDir(), sessionID+".jsonl")
+	lines := `{"type":"user","uuid":"u1","timestamp":"2025-06-01T10:00:00Z","message":{"role":"user","content":"Write some code"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-06-01T10:00:09Z","message":{"id":"msg_1","role":"assistant","model":"claude-opus-4-1-20250805","usage":{"input_tokens":12,"output_tokens":340,"cache_read_input_tokens":5600,"cache_creation_input_tokens":780}}}
+`
+	if err := os.WriteFile(transcriptPath, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hook.RecordPromptStart(database, &hook.HookInput{
+		SessionID:  sessionID,
+		Cwd:        repoDir,
+		PromptText: "Write some code",
+		AgentType:  "claude-code",
+		EventType:  hook.EventPromptStart,
+	}); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+	if err := hook.RecordPromptEnd(database, &hook.HookInput{
+		SessionID:      sessionID,
+		Cwd:            repoDir,
+		AgentType:      "claude-code",
+		EventType:      hook.EventPromptEnd,
+		TranscriptPath: transcriptPath,
+	}); err != nil {
+		t.Fatalf("RecordPromptEnd: %v", err)
+	}
+
+	var model string
+	var in, out, cacheRead, cacheWrite int64
+	if err := database.QueryRow(
+		`SELECT model, input_tokens, output_tokens, cache_read_tokens, cache_write_tokens
+		 FROM prompts WHERE session_id = ?`, sessionID,
+	).Scan(&model, &in, &out, &cacheRead, &cacheWrite); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if model != "claude-opus-4-1-20250805" {
+		t.Errorf("model: got %q", model)
+	}
+	if in != 12 || out != 340 || cacheRead != 5600 || cacheWrite != 780 {
+		t.Errorf("tokens: got in=%d out=%d cacheRead=%d cacheWrite=%d", in, out, cacheRead, cacheWrite)
+	}
+}
+
+func TestPromptEnd_GitErrorsDontLoseUsage(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	sessionID := "session-usage-002"
+
+	transcriptPath := filepath.Join(t.TempDir(), sessionID+".jsonl")
+	lines := `{"type":"user","uuid":"u1","timestamp":"2025-06-01T10:00:00Z","message":{"role":"user","content":"Write some code"}}
+{"type":"assistant","uuid":"a1","timestamp":"2025-06-01T10:00:09Z","message":{"id":"msg_1","role":"assistant","model":"claude-opus-4-1-20250805","usage":{"input_tokens":12,"output_tokens":340}}}
+`
+	if err := os.WriteFile(transcriptPath, []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := hook.RecordPromptStart(database, &hook.HookInput{
+		SessionID:  sessionID,
+		Cwd:        repoDir,
+		PromptText: "Write some code",
+		AgentType:  "claude-code",
+		EventType:  hook.EventPromptStart,
+	}); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+	// A start snapshot git no longer has makes the diff fail.
+	if _, err := database.Exec(
+		`UPDATE prompts SET tree_start = '0123456789abcdef0123456789abcdef01234567' WHERE session_id = ?`, sessionID,
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.RecordPromptEnd(database, &hook.HookInput{
+		SessionID:      sessionID,
+		Cwd:            repoDir,
+		AgentType:      "claude-code",
+		EventType:      hook.EventPromptEnd,
+		TranscriptPath: transcriptPath,
+	}); err != nil {
+		t.Fatalf("RecordPromptEnd: %v", err)
+	}
+
+	var status string
+	var out int64
+	if err := database.QueryRow(
+		`SELECT status, output_tokens FROM prompts WHERE session_id = ?`, sessionID,
+	).Scan(&status, &out); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if status != db.StatusCompleted || out != 340 {
+		t.Errorf("got status %q, %d output tokens; want %q, 340", status, out, db.StatusCompleted)
+	}
+}
+
+func TestTurnComplete(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	input := &hook.HookInput{
+		SessionID:   "codex-thread-001",
+		Cwd:         repoDir,
+		PromptText:  "Rename the package",
+		AgentType:   "codex",
+		EventType:   hook.EventTurnComplete,
+		SubmittedAt: time.Now().Add(-90 * time.Second),
+	}
+	if err := hook.RecordTurnComplete(database, input); err != nil {
+		t.Fatalf("RecordTurnComplete: %v", err)
+	}
+
+	var agentType string
+	var secs float64
+	if err := database.QueryRow(
+		`SELECT agent_type, (julianday(completed_at) - julianday(submitted_at)) * 86400
+		 FROM prompts WHERE session_id = ?`, input.SessionID,
+	).Scan(&agentType, &secs); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if agentType != "codex" {
+		t.Errorf("agent_type: got %q", agentType)
+	}
+	if secs < 89 || secs > 92 {
+		t.Errorf("duration: got %vs, want ~90s", secs)
+	}
+
+	// The start state wasn't reported, so it must not be taken from the end.
+	var hashStart, branchStart, treeStart sql.NullString
+	var dirtyStart sql.NullBool
+	if err := database.QueryRow(
+		`SELECT git_hash_start, branch_start, dirty_start, tree_start FROM prompts WHERE session_id = ?`, input.SessionID,
+	).Scan(&hashStart, &branchStart, &dirtyStart, &treeStart); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if hashStart.Valid || branchStart.Valid || dirtyStart.Valid || treeStart.Valid {
+		t.Errorf("start state should be NULL, got hash=%v branch=%v dirty=%v tree=%v", hashStart, branchStart, dirtyStart, treeStart)
+	}
+}
+
+func TestTurnComplete_ReportedStartHead(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	git := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command("git", append([]string{"-C", repoDir}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	head := git("rev-parse", "HEAD")
+	git("commit", "-q", "--allow-empty", "-m", "agent commit")
+
+	input := &hook.HookInput{
+		SessionID:   "codex-thread-002",
+		Cwd:         repoDir,
+		PromptText:  "Commit something",
+		AgentType:   "codex",
+		EventType:   hook.EventTurnComplete,
+		SubmittedAt: time.Now().Add(-time.Minute),
+		HeadStart:   head,
+		BranchStart: git("symbolic-ref", "--short", "HEAD"),
+	}
+	if err := hook.RecordTurnComplete(database, input); err != nil {
+		t.Fatalf("RecordTurnComplete: %v", err)
+	}
+
+	var subject string
+	if err := database.QueryRow(
+		`SELECT c.subject FROM prompt_commits c JOIN prompts p ON p.id = c.prompt_id WHERE p.session_id = ?`,
+		input.SessionID,
+	).Scan(&subject); err != nil {
+		t.Fatalf("query commits: %v", err)
+	}
+	if subject != "agent commit" {
+		t.Errorf("commit subject: got %q", subject)
+	}
+}
+
+func TestPromptEnd_RecordsExitCode(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	input := &hook.HookInput{
+		SessionID:  "session-exit-001",
+		Cwd:        repoDir,
+		PromptText: "aider --yes",
+		AgentType:  "aider",
+		EventType:  hook.EventPromptStart,
+	}
+	if err := hook.RecordPromptStart(database, input); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+	code := 2
+	input.EventType = hook.EventPromptEnd
+	input.ExitCode = &code
+	if err := hook.RecordPromptEnd(database, input); err != nil {
+		t.Fatalf("RecordPromptEnd: %v", err)
+	}
+
+	var got int
+	var hashStart, hashEnd string
+	if err := database.QueryRow(
+		`SELECT exit_code, git_hash_start, git_hash_end FROM prompts WHERE session_id = ?`, input.SessionID,
+	).Scan(&got, &hashStart, &hashEnd); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if got != 2 {
+		t.Errorf("exit_code: got %d, want 2", got)
+	}
+	if hashStart == "" || hashStart != hashEnd {
+		t.Errorf("git hashes: got start=%q end=%q", hashStart, hashEnd)
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	input := &hook.HookInput{
+		SessionID:   "session-lifecycle-001",
+		Cwd:         repoDir,
+		AgentType:   "claude-code",
+		EventType:   hook.EventSessionStart,
+		StartSource: "startup",
+	}
+	if err := hook.RecordSessionStart(database, input); err != nil {
+		t.Fatalf("RecordSessionStart: %v", err)
+	}
+
+	// A prompt in the same session must reuse the session row.
+	if err := hook.RecordPromptStart(database, &hook.HookInput{
+		SessionID: input.SessionID,
+		Cwd:       repoDir,
+		AgentType: "claude-code",
+		EventType: hook.EventPromptStart,
+	}); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+
+	end := &hook.HookInput{
+		SessionID: input.SessionID,
+		Cwd:       repoDir,
+		AgentType: "claude-code",
+		EventType: hook.EventSessionEnd,
+		EndReason: "logout",
+	}
+	if err := hook.RecordSessionEnd(database, end); err != nil {
+		t.Fatalf("RecordSessionEnd: %v", err)
+	}
+
+	var source, reason string
+	var ended sql.NullString
+	query := `SELECT COALESCE(start_source, ''), COALESCE(end_reason, ''), ended_at FROM sessions WHERE id = ?`
+	if err := database.QueryRow(query, input.SessionID).Scan(&source, &reason, &ended); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if source != "startup" || reason != "logout" || !ended.Valid {
+		t.Errorf("after end: source=%q reason=%q ended=%v", source, reason, ended)
+	}
+
+	// Resuming reopens the session but keeps how it originally started.
+	input.StartSource = "resume"
+	if err := hook.RecordSessionStart(database, input); err != nil {
+		t.Fatalf("RecordSessionStart (resume): %v", err)
+	}
+	if err := database.QueryRow(query, input.SessionID).Scan(&source, &reason, &ended); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if source != "startup" || reason != "" || ended.Valid {
+		t.Errorf("after resume: source=%q reason=%q ended=%v", source, reason, ended)
+	}
+
+	// The length is the sum of the runs, not the time since the first start.
+	if _, err := database.Exec(
+		`UPDATE sessions
+		 SET started_at = datetime('now', '-5 hours'), resumed_at = datetime('now', '-10 minutes'), run_seconds = 60
+		 WHERE id = ?`, input.SessionID,
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err := hook.RecordSessionEnd(database, end); err != nil {
+		t.Fatalf("RecordSessionEnd (resumed): %v", err)
+	}
+	var runSeconds float64
+	if err := database.QueryRow(`SELECT run_seconds FROM sessions WHERE id = ?`, input.SessionID).Scan(&runSeconds); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if runSeconds < 660 || runSeconds > 670 {
+		t.Errorf("run_seconds after resumed run: got %v, want about 660", runSeconds)
+	}
+}
+
+func TestNotificationWaits(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	sessionID := "session-waits-001"
+	input := func(et hook.EventType) *hook.HookInput {
+		return &hook.HookInput{
+			SessionID:        sessionID,
+			Cwd:              repoDir,
+			AgentType:        "claude-code",
+			EventType:        et,
+			ToolName:         "Bash",
+			NotificationType: "permission_prompt",
+			Message:          "Claude needs your permission to use Bash",
+		}
+	}
+	countWaits := func() (total, open int) {
+		t.Helper()
+		if err := database.QueryRow(
+			`SELECT COUNT(*), COUNT(*) - COUNT(ended_at) FROM prompt_waits WHERE session_id = ?`, sessionID,
+		).Scan(&total, &open); err != nil {
+			t.Fatalf("query: %v", err)
+		}
+		return total, open
+	}
+
+	// Outside a prompt: ignored.
+	if err := hook.RecordNotification(database, input(hook.EventNotification)); err != nil {
+		t.Fatalf("RecordNotification: %v", err)
+	}
+	if total, _ := countWaits(); total != 0 {
+		t.Errorf("expected no waits outside a prompt, got %d", total)
+	}
+
+	if err := hook.RecordPromptStart(database, input(hook.EventPromptStart)); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+	if err := hook.RecordToolStart(database, input(hook.EventToolStart)); err != nil {
+		t.Fatalf("RecordToolStart: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := hook.RecordNotification(database, input(hook.EventNotification)); err != nil {
+			t.Fatalf("RecordNotification: %v", err)
+		}
+	}
+	if total, open := countWaits(); total != 1 || open != 1 {
+		t.Errorf("repeated notification: got total=%d open=%d, want 1 open", total, open)
+	}
+
+	// The next tool starting means the user answered, without counting the
+	// approved tool's run time as waiting.
+	if err := hook.RecordToolStart(database, input(hook.EventToolStart)); err != nil {
+		t.Fatalf("RecordToolStart: %v", err)
+	}
+	if _, open := countWaits(); open != 0 {
+		t.Errorf("expected wait closed by the next tool-start, %d open", open)
+	}
+
+	// Failing that, the tool finishing does.
+	if err := hook.RecordNotification(database, input(hook.EventNotification)); err != nil {
+		t.Fatalf("RecordNotification: %v", err)
+	}
+	if err := hook.RecordToolEnd(database, input(hook.EventToolEnd)); err != nil {
+		t.Fatalf("RecordToolEnd: %v", err)
+	}
+	if _, open := countWaits(); open != 0 {
+		t.Errorf("expected wait closed by tool-end, %d open", open)
+	}
+
+	if err := hook.RecordNotification(database, input(hook.EventNotification)); err != nil {
+		t.Fatalf("RecordNotification: %v", err)
+	}
+	if err := hook.RecordPromptEnd(database, input(hook.EventPromptEnd)); err != nil {
+		t.Fatalf("RecordPromptEnd: %v", err)
+	}
+	if total, open := countWaits(); total != 3 || open != 0 {
+		t.Errorf("after prompt-end: got total=%d open=%d, want 3 closed", total, open)
+	}
+}
+
+func TestSubagents(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	sessionID := "session-subagents-001"
+	base := hook.HookInput{SessionID: sessionID, Cwd: repoDir, AgentType: "claude-code"}
+
+	start := base
+	start.EventType = hook.EventPromptStart
+	if err := hook.RecordPromptStart(database, &start); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+
+	for _, id := range []string{"toolu_a", "toolu_b"} {
+		task := base
+		task.EventType = hook.EventToolStart
+		task.ToolName = "Task"
+		task.ToolUseID = id
+		task.ToolInput = `{"subagent_type":"Explore","description":"Find ` + id + `","prompt":"..."}`
+		if err := hook.RecordToolStart(database, &task); err != nil {
+			t.Fatalf("RecordToolStart(Task): %v", err)
+		}
+	}
+
+	// Other tools don't create subagent runs.
+	read := base
+	read.EventType = hook.EventToolStart
+	read.ToolName = "Read"
+	read.ToolUseID = "toolu_read"
+	if err := hook.RecordToolStart(database, &read); err != nil {
+		t.Fatalf("RecordToolStart(Read): %v", err)
+	}
+
+	stop := base
+	stop.EventType = hook.EventSubagentStop
+	stop.SubagentID = "agent-1"
+	if err := hook.RecordSubagentStop(database, &stop); err != nil {
+		t.Fatalf("RecordSubagentStop: %v", err)
+	}
+
+	taskEnd := base
+	taskEnd.EventType = hook.EventToolEnd
+	taskEnd.ToolName = "Task"
+	taskEnd.ToolUseID = "toolu_b"
+	if err := hook.RecordToolEnd(database, &taskEnd); err != nil {
+		t.Fatalf("RecordToolEnd(Task): %v", err)
+	}
+
+	rows, err := database.Query(`
+		SELECT s.tool_use_id, s.subagent_type, s.description, COALESCE(s.agent_id, ''), s.completed_at IS NOT NULL
+		FROM subagents s JOIN prompts p ON p.id = s.prompt_id
+		WHERE s.session_id = ?
+		ORDER BY s.tool_use_id`, sessionID)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	defer rows.Close()
+
+	type run struct {
+		toolUseID, subagentType, description, agentID string
+		done                                          bool
+	}
+	var got []run
+	for rows.Next() {
+		var r run
+		if err := rows.Scan(&r.toolUseID, &r.subagentType, &r.description, &r.agentID, &r.done); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, r)
+	}
+	want := []run{
+		{"toolu_a", "Explore", "Find toolu_a", "agent-1", true},
+		{"toolu_b", "Explore", "Find toolu_b", "", true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d subagent runs, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("run %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSubagents_EndBeforeStart(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	repoDir := makeCommit(t)
+	sessionID := "session-subagents-002"
+	base := hook.HookInput{SessionID: sessionID, Cwd: repoDir, AgentType: "claude-code"}
+
+	start := base
+	start.EventType = hook.EventPromptStart
+	if err := hook.RecordPromptStart(database, &start); err != nil {
+		t.Fatalf("RecordPromptStart: %v", err)
+	}
+
+	// Hooks run async, so the Task's tool-end can be recorded first.
+	task := base
+	task.ToolName = "Task"
+	task.ToolUseID = "toolu_a"
+	task.ToolInput = `{"subagent_type":"Explore","description":"Find things"}`
+	task.EventType = hook.EventToolEnd
+	if err := hook.RecordToolEnd(database, &task); err != nil {
+		t.Fatalf("RecordToolEnd(Task): %v", err)
+	}
+	task.EventType = hook.EventToolStart
+	if err := hook.RecordToolStart(database, &task); err != nil {
+		t.Fatalf("RecordToolStart(Task): %v", err)
+	}
+
+	var done, ordered bool
+	if err := database.QueryRow(
+		`SELECT completed_at IS NOT NULL, completed_at >= started_at FROM subagents WHERE session_id = ?`, sessionID,
+	).Scan(&done, &ordered); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if !done || !ordered {
+		t.Errorf("subagent run: completed %v, ends after start %v; want both", done, ordered)
+	}
+}
@@ -0,0 +1,159 @@
+// Package importer backfills the database from agent transcripts recorded
+// before agentstats was installed.
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/project"
+	"github.com/dansimau/agentstats/internal/transcript"
+)
+
+// duplicateWindowSecs is how close a hook-recorded prompt's submit time must
+// be to a transcript prompt for them to be considered the same prompt.
+const duplicateWindowSecs = 5
+
+// Result summarises an import run.
+type Result struct {
+	Transcripts int // transcript files read
+	Sessions    int // sessions created
+	Prompts     int // prompts inserted
+	Skipped     int // prompts already in the database
+
+	// Unreadable holds an error, naming the path, for each transcript or
+	// directory that couldn't be read. These are skipped.
+	Unreadable []error
+}
+
+// ImportClaude walks dir (normally ~/.claude/projects) for Claude Code
+// transcripts and inserts their sessions and prompts. Prompts are keyed by
+// the transcript entry UUID, and prompts already recorded live by the hooks
+// are detected by submit time, so re-running an import never duplicates rows.
+// Transcripts that can't be read are skipped and listed in the result.
+func ImportClaude(database *sql.DB, dir string) (*Result, error) {
+	res := &Result{}
+	projects := map[string]*project.Project{}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			// Returning nil for a directory skips it.
+			res.Unreadable = append(res.Unreadable, err)
+			return nil
+		}
+		if d.IsDir() || filepath.Ext(path) != ".jsonl" {
+			return nil
+		}
+
+		turns, err := transcript.Read(path)
+		if err != nil {
+			res.Unreadable = append(res.Unreadable, fmt.Errorf("%s: %w", path, err))
+			return nil
+		}
+		res.Transcripts++
+
+		sessionID := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+		return importSession(database, sessionID, turns, projects, res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func importSession(database *sql.DB, fallbackID string, turns []transcript.Turn, projects map[string]*project.Project, res *Result) error {
+	// Only prompts the agent responded to count as work.
+	var done []transcript.Turn
+	for _, t := range turns {
+		if !t.CompletedAt.IsZero() && t.Cwd != "" {
+			done = append(done, t)
+		}
+	}
+	if len(done) == 0 {
+		return nil
+	}
+
+	sessionID := done[0].SessionID
+	if sessionID == "" {
+		sessionID = fallbackID
+	}
+
+	// The session belongs to the project it started in, as with the hooks.
+	cwd := done[0].Cwd
+	proj, ok := projects[cwd]
+	if !ok {
+		var err error
+		proj, err = project.Upsert(database, cwd)
+		if err != nil {
+			return fmt.Errorf("upsert project: %w", err)
+		}
+		projects[cwd] = proj
+	}
+
+	tx, err := database.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`INSERT OR IGNORE INTO sessions (id, project_id, agent_type, started_at) VALUES (?, ?, 'claude-code', ?)`,
+		sessionID, proj.ID, db.FormatTime(done[0].SubmittedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("insert session: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		res.Sessions++
+	}
+
+	// Prompts must reference the session's actual project, which differs from
+	// proj if the session was already recorded by the hooks.
+	var projectID string
+	if err := tx.QueryRow(`SELECT project_id FROM sessions WHERE id = ?`, sessionID).Scan(&projectID); err != nil {
+		return fmt.Errorf("query session: %w", err)
+	}
+
+	for _, t := range done {
+		submitted := db.FormatTime(t.SubmittedAt)
+
+		var exists int
+		if err := tx.QueryRow(
+			`SELECT COUNT(*) FROM prompts
+			 WHERE id = ?
+			    OR (session_id = ? AND ABS(julianday(submitted_at) - julianday(?)) * 86400 < ?)`,
+			t.UUID, sessionID, submitted, duplicateWindowSecs,
+		).Scan(&exists); err != nil {
+			return fmt.Errorf("query prompt: %w", err)
+		}
+		if exists > 0 {
+			res.Skipped++
+			continue
+		}
+
+		var model interface{}
+		if t.Model != "" {
+			model = t.Model
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO prompts (
+			     id, session_id, project_id, prompt_text, submitted_at, completed_at, status, agent_type,
+			     model, input_tokens, output_tokens, cache_read_tokens, cache_write_tokens)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, 'claude-code', ?, ?, ?, ?, ?)`,
+			t.UUID, sessionID, projectID, t.Prompt, submitted, db.FormatTime(t.CompletedAt), db.StatusCompleted,
+			model, t.Usage.InputTokens, t.Usage.OutputTokens, t.Usage.CacheReadTokens, t.Usage.CacheWriteTokens,
+		); err != nil {
+			return fmt.Errorf("insert prompt: %w", err)
+		}
+		res.Prompts++
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,139 @@
+package importer_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dansimau/agentstats/internal/db"
+	"github.com/dansimau/agentstats/internal/importer"
+)
+
+func writeTranscript(t *testing.T, dir, sessionID, cwd string) {
+	t.Helper()
+	lines := fmt.Sprintf(`{"type":"user","uuid":"11111111-1111-1111-1111-111111111111","sessionId":%[1]q,"cwd":%[2]q,"timestamp":"2025-06-01T10:00:00Z","message":{"role":"user","content":"Add a README"}}
+{"type":"assistant","uuid":"a1","sessionId":%[1]q,"cwd":%[2]q,"timestamp":"2025-06-01T10:02:30Z","message":{"id":"msg_1","role":"assistant","model":"claude-sonnet-4-20250514","usage":{"input_tokens":10,"output_tokens":20}}}
+{"type":"user","uuid":"22222222-2222-2222-2222-222222222222","sessionId":%[1]q,"cwd":%[2]q,"timestamp":"2025-06-01T10:10:00Z","message":{"role":"user","content":"Now add tests"}}
+{"type":"assistant","uuid":"a2","sessionId":%[1]q,"cwd":%[2]q,"timestamp":"2025-06-01T10:11:00Z","message":{"id":"msg_2","role":"assistant","model":"claude-sonnet-4-20250514","usage":{"input_tokens":1,"output_tokens":2}}}
+{"type":"user","uuid":"33333333-3333-3333-3333-333333333333","sessionId":%[1]q,"cwd":%[2]q,"timestamp":"2025-06-01T10:20:00Z","message":{"role":"user","content":"No response to this one"}}
+`, sessionID, cwd)
+
+	projDir := filepath.Join(dir, "-encoded-project-dir")
+	if err := os.MkdirAll(projDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projDir, sessionID+".jsonl"), []byte(lines), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportClaude_Idempotent(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	dir := t.TempDir()
+	writeTranscript(t, dir, "session-import-001", t.TempDir())
+
+	res, err := importer.ImportClaude(database, dir)
+	if err != nil {
+		t.Fatalf("ImportClaude: %v", err)
+	}
+	if res.Transcripts != 1 || res.Sessions != 1 || res.Prompts != 2 || res.Skipped != 0 {
+		t.Errorf("first import: got %+v", res)
+	}
+
+	var secs float64
+	if err := database.QueryRow(
+		`SELECT (julianday(completed_at) - julianday(submitted_at)) * 86400
+		 FROM prompts WHERE prompt_text = 'Add a README'`,
+	).Scan(&secs); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if int(secs+0.5) != 150 {
+		t.Errorf("duration: got %vs, want 150s", secs)
+	}
+
+	res, err = importer.ImportClaude(database, dir)
+	if err != nil {
+		t.Fatalf("second ImportClaude: %v", err)
+	}
+	if res.Sessions != 0 || res.Prompts != 0 || res.Skipped != 2 {
+		t.Errorf("second import: got %+v", res)
+	}
+
+	var count int
+	if err := database.QueryRow(`SELECT COUNT(*) FROM prompts`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 prompts after re-import, got %d", count)
+	}
+}
+
+func TestImportClaude_SkipsHookRecordedPrompts(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	cwd := t.TempDir()
+	sessionID := "session-import-002"
+
+	// The hooks recorded the first prompt live, a second after the transcript.
+	if _, err := database.Exec(`INSERT INTO projects (id, directory) VALUES ('p1', ?)`, cwd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(`INSERT INTO sessions (id, project_id) VALUES (?, 'p1')`, sessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Exec(
+		`INSERT INTO prompts (id, session_id, project_id, submitted_at, completed_at)
+		 VALUES ('live', ?, 'p1', '2025-06-01 10:00:01', '2025-06-01 10:02:31')`, sessionID,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writeTranscript(t, dir, sessionID, cwd)
+
+	res, err := importer.ImportClaude(database, dir)
+	if err != nil {
+		t.Fatalf("ImportClaude: %v", err)
+	}
+	if res.Prompts != 1 || res.Skipped != 1 || res.Sessions != 0 {
+		t.Errorf("got %+v", res)
+	}
+}
+
+func TestImportClaude_SkipsUnreadableTranscripts(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	dir := t.TempDir()
+	writeTranscript(t, dir, "session-import-003", t.TempDir())
+	// A dangling link can't be opened.
+	bad := filepath.Join(dir, "-encoded-project-dir", "session-import-004.jsonl")
+	if err := os.Symlink(filepath.Join(dir, "missing.jsonl"), bad); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := importer.ImportClaude(database, dir)
+	if err != nil {
+		t.Fatalf("ImportClaude: %v", err)
+	}
+	if res.Transcripts != 1 || res.Prompts != 2 {
+		t.Errorf("readable transcript: got %+v", res)
+	}
+	if len(res.Unreadable) != 1 || !strings.Contains(res.Unreadable[0].Error(), bad) {
+		t.Errorf("unreadable: got %v, want one error naming %s", res.Unreadable, bad)
+	}
+}
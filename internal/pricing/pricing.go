@@ -0,0 +1,88 @@
+// Package pricing estimates the cost of model token usage.
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Price is the cost of a model's tokens in USD per million tokens.
+type Price struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheRead  float64 `json:"cache_read"`
+	CacheWrite float64 `json:"cache_write"`
+}
+
+// Cost returns the USD cost of the given token counts at this price.
+func (p Price) Cost(input, output, cacheRead, cacheWrite int64) float64 {
+	return (float64(input)*p.Input +
+		float64(output)*p.Output +
+		float64(cacheRead)*p.CacheRead +
+		float64(cacheWrite)*p.CacheWrite) / 1_000_000
+}
+
+// Table maps model name prefixes to prices.
+type Table map[string]Price
+
+// UnmarshalJSON implements json.Unmarshaler, merging the decoded entries into
+// t field by field. Prices an entry leaves out keep the value t already gives
+// that model, so overriding one price doesn't zero the others.
+func (t *Table) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if *t == nil {
+		*t = Table{}
+	}
+	// Shorter prefixes first, so a new entry starts from its family's price
+	// as overridden in the same file.
+	models := make([]string, 0, len(raw))
+	for model := range raw {
+		models = append(models, model)
+	}
+	sort.Slice(models, func(i, j int) bool { return len(models[i]) < len(models[j]) })
+	for _, model := range models {
+		p, _ := t.Lookup(model)
+		if err := json.Unmarshal(raw[model], &p); err != nil {
+			return fmt.Errorf("price for %s: %w", model, err)
+		}
+		(*t)[model] = p
+	}
+	return nil
+}
+
+// Defaults returns the built-in price table. Keys are model name prefixes so
+// that dated snapshots (e.g. claude-sonnet-4-20250514) match their family.
+func Defaults() Table {
+	return Table{
+		"claude-opus-4-5":   {Input: 5, Output: 25, CacheRead: 0.50, CacheWrite: 6.25},
+		"claude-opus-4":     {Input: 15, Output: 75, CacheRead: 1.50, CacheWrite: 18.75},
+		"claude-sonnet-4":   {Input: 3, Output: 15, CacheRead: 0.30, CacheWrite: 3.75},
+		"claude-haiku-4-5":  {Input: 1, Output: 5, CacheRead: 0.10, CacheWrite: 1.25},
+		"claude-3-opus":     {Input: 15, Output: 75, CacheRead: 1.50, CacheWrite: 18.75},
+		"claude-3-7-sonnet": {Input: 3, Output: 15, CacheRead: 0.30, CacheWrite: 3.75},
+		"claude-3-5-sonnet": {Input: 3, Output: 15, CacheRead: 0.30, CacheWrite: 3.75},
+		"claude-3-5-haiku":  {Input: 0.80, Output: 4, CacheRead: 0.08, CacheWrite: 1},
+		"claude-3-haiku":    {Input: 0.25, Output: 1.25, CacheRead: 0.03, CacheWrite: 0.30},
+	}
+}
+
+// Lookup returns the price for model, using the longest matching prefix.
+func (t Table) Lookup(model string) (Price, bool) {
+	var best string
+	found := false
+	for prefix := range t {
+		if strings.HasPrefix(model, prefix) && (!found || len(prefix) > len(best)) {
+			best = prefix
+			found = true
+		}
+	}
+	if !found {
+		return Price{}, false
+	}
+	return t[best], true
+}
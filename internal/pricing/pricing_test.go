@@ -0,0 +1,41 @@
+package pricing_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dansimau/agentstats/internal/pricing"
+)
+
+func TestLookup_LongestPrefix(t *testing.T) {
+	table := pricing.Defaults()
+
+	p, ok := table.Lookup("claude-opus-4-5-20251101")
+	if !ok {
+		t.Fatal("expected match for claude-opus-4-5")
+	}
+	if p.Input != 5 {
+		t.Errorf("opus-4-5 input price: got %v, want 5", p.Input)
+	}
+
+	p, ok = table.Lookup("claude-opus-4-1-20250805")
+	if !ok {
+		t.Fatal("expected match for claude-opus-4-1")
+	}
+	if p.Input != 15 {
+		t.Errorf("opus-4-1 input price: got %v, want 15", p.Input)
+	}
+
+	if _, ok := table.Lookup("gpt-5-codex"); ok {
+		t.Error("expected no match for unknown model")
+	}
+}
+
+func TestCost(t *testing.T) {
+	p := pricing.Price{Input: 3, Output: 15, CacheRead: 0.30, CacheWrite: 3.75}
+	got := p.Cost(1_000_000, 100_000, 2_000_000, 0)
+	want := 3 + 1.5 + 0.6
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("Cost: got %v, want %v", got, want)
+	}
+}
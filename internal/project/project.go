@@ -4,7 +4,10 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/dansimau/agentstats/internal/gitx"
 	"github.com/google/uuid"
@@ -15,10 +18,12 @@ type Project struct {
 	ID        string
 	GitOrigin string // empty if no remote
 	Directory string
+	Name      string // empty unless renamed; see ShortName
 }
 
 // Resolve returns the canonical project directory and git origin for a
-// given working directory.
+// given working directory. All worktrees of a repo resolve to the directory
+// of its main worktree, so they share one project.
 func Resolve(cwd string) (dir string, origin string) {
 	abs, err := filepath.Abs(cwd)
 	if err != nil {
@@ -30,7 +35,7 @@ func Resolve(cwd string) (dir string, origin string) {
 	}
 
 	if gitx.IsRepo(resolved) {
-		root := gitx.RepoRoot(resolved)
+		root := gitx.MainWorktree(resolved)
 		if root != "" {
 			resolved = root
 		}
@@ -39,8 +44,94 @@ func Resolve(cwd string) (dir string, origin string) {
 	return resolved, origin
 }
 
+// SubprojectRules map directories within a project to sub-projects, e.g. the
+// services of a monorepo.
+type SubprojectRules struct {
+	// Prefixes maps a directory, relative to the repo root, to the name of
+	// the sub-project it contains. The longest matching prefix wins.
+	Prefixes map[string]string `json:"prefixes"`
+
+	// Detect lists marker files, such as go.mod, whose directory is taken to
+	// be a sub-project when no prefix matches. The nearest one above cwd
+	// (below the root) names the sub-project after its path.
+	Detect []string `json:"detect"`
+}
+
+// Subdir returns the path of cwd relative to the top of its worktree (or
+// the project directory outside git), using forward slashes. Returns "" at
+// the top itself or if cwd is outside it.
+func Subdir(cwd string) string {
+	return relSubdir(worktreeRoot(cwd), cwd)
+}
+
+// worktreeRoot returns the top of the worktree containing cwd, or the
+// project directory outside git.
+func worktreeRoot(cwd string) string {
+	if root := gitx.RepoRoot(cwd); root != "" {
+		return root
+	}
+	dir, _ := Resolve(cwd)
+	return dir
+}
+
+func relSubdir(root, cwd string) string {
+	abs, err := filepath.Abs(cwd)
+	if err != nil {
+		abs = cwd
+	}
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		resolved = abs
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Subproject returns the sub-project that cwd belongs to under rules, or ""
+// if none applies.
+func Subproject(cwd string, rules SubprojectRules) string {
+	root := worktreeRoot(cwd)
+	subdir := relSubdir(root, cwd)
+	if subdir == "" {
+		return ""
+	}
+
+	var best, name string
+	for prefix, n := range rules.Prefixes {
+		prefix = strings.Trim(filepath.ToSlash(prefix), "/")
+		if prefix == "" || len(prefix) <= len(best) {
+			continue
+		}
+		if subdir == prefix || strings.HasPrefix(subdir, prefix+"/") {
+			best, name = prefix, n
+		}
+	}
+	if best != "" {
+		if name == "" {
+			name = best
+		}
+		return name
+	}
+
+	// Walk up from cwd to just below the root looking for a marker.
+	for dir := subdir; dir != "." && dir != ""; dir = path.Dir(dir) {
+		for _, marker := range rules.Detect {
+			if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(dir), marker)); err == nil {
+				return dir
+			}
+		}
+	}
+	return ""
+}
+
 // Upsert finds or creates a project for the given directory and optional
-// git origin. Origin-first matching handles re-clones to new paths.
+// git origin. Origin-first matching handles re-clones to new paths, and
+// origins are compared by gitx.OriginKey, so ssh and https clones of a repo
+// are one project. A project keeps its directory while it exists, so clones
+// used side by side don't move it back and forth.
 func Upsert(db *sql.DB, cwd string) (*Project, error) {
 	dir, origin := Resolve(cwd)
 
@@ -51,14 +142,8 @@ func Upsert(db *sql.DB, cwd string) (*Project, error) {
 			return nil, err
 		}
 		if p != nil {
-			// Update directory if it has changed.
-			if p.Directory != dir {
-				if _, err := db.Exec(
-					`UPDATE projects SET directory=? WHERE id=?`, dir, p.ID,
-				); err != nil {
-					return nil, fmt.Errorf("update project dir: %w", err)
-				}
-				p.Directory = dir
+			if err := repoint(db, p, dir); err != nil {
+				return nil, err
 			}
 			return p, nil
 		}
@@ -75,19 +160,39 @@ func Upsert(db *sql.DB, cwd string) (*Project, error) {
 
 	// Create new project.
 	id := uuid.New().String()
-	var originVal interface{}
+	var originVal, keyVal interface{}
 	if origin != "" {
-		originVal = origin
+		originVal, keyVal = origin, gitx.OriginKey(origin)
 	}
 	if _, err := db.Exec(
-		`INSERT INTO projects (id, git_origin, directory) VALUES (?, ?, ?)`,
-		id, originVal, dir,
+		`INSERT INTO projects (id, git_origin, origin_key, directory) VALUES (?, ?, ?, ?)`,
+		id, originVal, keyVal, dir,
 	); err != nil {
 		return nil, fmt.Errorf("insert project: %w", err)
 	}
 	return &Project{ID: id, GitOrigin: origin, Directory: dir}, nil
 }
 
+// repoint moves p to dir if its directory no longer exists, e.g. because the
+// repo was re-cloned elsewhere, and no other project has dir.
+func repoint(db *sql.DB, p *Project, dir string) error {
+	if p.Directory == dir {
+		return nil
+	}
+	if _, err := os.Stat(p.Directory); err == nil {
+		return nil
+	}
+	other, err := findByDir(db, dir)
+	if err != nil || other != nil {
+		return err
+	}
+	if _, err := db.Exec(`UPDATE projects SET directory=? WHERE id=?`, dir, p.ID); err != nil {
+		return fmt.Errorf("update project dir: %w", err)
+	}
+	p.Directory = dir
+	return nil
+}
+
 // Find looks up a project for the given directory (read-only).
 func Find(db *sql.DB, cwd string) (*Project, error) {
 	dir, origin := Resolve(cwd)
@@ -106,9 +211,9 @@ func Find(db *sql.DB, cwd string) (*Project, error) {
 
 // FindByID looks up a project by its ID.
 func FindByID(db *sql.DB, id string) (*Project, error) {
-	row := db.QueryRow(`SELECT id, COALESCE(git_origin,''), directory FROM projects WHERE id=?`, id)
+	row := db.QueryRow(`SELECT id, COALESCE(git_origin,''), directory, COALESCE(name,'') FROM projects WHERE id=?`, id)
 	p := &Project{}
-	if err := row.Scan(&p.ID, &p.GitOrigin, &p.Directory); err != nil {
+	if err := row.Scan(&p.ID, &p.GitOrigin, &p.Directory, &p.Name); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -117,13 +222,18 @@ func FindByID(db *sql.DB, id string) (*Project, error) {
 	return p, nil
 }
 
+// findByOrigin looks up a project by the key of its own origin, or of a
+// duplicate merged into it.
 func findByOrigin(db *sql.DB, origin string) (*Project, error) {
 	row := db.QueryRow(
-		`SELECT id, COALESCE(git_origin,''), directory FROM projects WHERE git_origin=?`,
-		origin,
+		`SELECT id, COALESCE(git_origin,''), directory, COALESCE(name,'') FROM projects
+		 WHERE origin_key = ?1 OR id = (SELECT project_id FROM project_origins WHERE origin_key = ?1)
+		 ORDER BY origin_key = ?1 DESC
+		 LIMIT 1`,
+		gitx.OriginKey(origin),
 	)
 	p := &Project{}
-	if err := row.Scan(&p.ID, &p.GitOrigin, &p.Directory); err != nil {
+	if err := row.Scan(&p.ID, &p.GitOrigin, &p.Directory, &p.Name); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -134,11 +244,11 @@ func findByOrigin(db *sql.DB, origin string) (*Project, error) {
 
 func findByDir(db *sql.DB, dir string) (*Project, error) {
 	row := db.QueryRow(
-		`SELECT id, COALESCE(git_origin,''), directory FROM projects WHERE directory=?`,
+		`SELECT id, COALESCE(git_origin,''), directory, COALESCE(name,'') FROM projects WHERE directory=?`,
 		dir,
 	)
 	p := &Project{}
-	if err := row.Scan(&p.ID, &p.GitOrigin, &p.Directory); err != nil {
+	if err := row.Scan(&p.ID, &p.GitOrigin, &p.Directory, &p.Name); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -147,51 +257,193 @@ func findByDir(db *sql.DB, dir string) (*Project, error) {
 	return p, nil
 }
 
-// ShortName returns a human-readable name for the project.
-func (p *Project) ShortName() string {
-	return filepath.Base(p.Directory)
+// List returns all projects, ordered by name.
+func List(db *sql.DB) ([]*Project, error) {
+	rows, err := db.Query(`SELECT id, COALESCE(git_origin,''), directory, COALESCE(name,'') FROM projects`)
+	if err != nil {
+		return nil, fmt.Errorf("query projects: %w", err)
+	}
+	defer rows.Close()
+
+	var projects []*Project
+	for rows.Next() {
+		p := &Project{}
+		if err := rows.Scan(&p.ID, &p.GitOrigin, &p.Directory, &p.Name); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		if a, b := projects[i].ShortName(), projects[j].ShortName(); a != b {
+			return a < b
+		}
+		return projects[i].Directory < projects[j].Directory
+	})
+	return projects, nil
 }
 
-// DisplayOrigin returns a cleaned-up display version of the git origin.
-func (p *Project) DisplayOrigin() string {
-	if p.GitOrigin == "" {
-		return ""
+// Lookup finds the project that ref refers to: a project ID or unique ID
+// prefix, a name as shown by ShortName, a directory, or a git origin in any
+// form. Returns nil if nothing matches, and an error if ref is ambiguous.
+func Lookup(db *sql.DB, ref string) (*Project, error) {
+	projects, err := List(db)
+	if err != nil {
+		return nil, err
 	}
-	// Strip common git URL noise for display.
-	s := p.GitOrigin
-	// ssh: git@github.com:user/repo.git -> github.com/user/repo
-	if len(s) > 4 && s[:4] == "git@" {
-		s = s[4:]
-		s = replaceFirst(s, ":", "/")
+
+	for _, match := range []func(p *Project) bool{
+		func(p *Project) bool { return p.ID == ref },
+		func(p *Project) bool { return p.ShortName() == ref },
+		func(p *Project) bool { return p.GitOrigin != "" && gitx.OriginKey(p.GitOrigin) == gitx.OriginKey(ref) },
+	} {
+		if p, err := pick(projects, ref, match); p != nil || err != nil {
+			return p, err
+		}
+	}
+	// Directories take precedence over ID prefixes.
+	if _, err := os.Stat(ref); err == nil {
+		return Find(db, ref)
 	}
-	// https://github.com/user/repo.git -> github.com/user/repo
-	for _, prefix := range []string{"https://", "http://"} {
-		if len(s) > len(prefix) && s[:len(prefix)] == prefix {
-			s = s[len(prefix):]
+	return pick(projects, ref, func(p *Project) bool { return strings.HasPrefix(p.ID, ref) })
+}
+
+// pick returns the one project that matches, nil if none do, or an error if
+// ref is ambiguous.
+func pick(projects []*Project, ref string, match func(p *Project) bool) (*Project, error) {
+	var found []*Project
+	for _, p := range projects {
+		if match(p) {
+			found = append(found, p)
 		}
 	}
-	// Strip trailing .git
-	if len(s) > 4 && s[len(s)-4:] == ".git" {
-		s = s[:len(s)-4]
+	if len(found) > 1 {
+		var dirs []string
+		for _, p := range found {
+			dirs = append(dirs, p.Directory)
+		}
+		return nil, fmt.Errorf("%q matches %d projects (%s); use a project ID instead",
+			ref, len(found), strings.Join(dirs, ", "))
 	}
-	return s
+	if len(found) == 1 {
+		return found[0], nil
+	}
+	return nil, nil
 }
 
-func replaceFirst(s, old, new string) string {
-	i := indexOf(s, old)
-	if i < 0 {
-		return s
+// Rename sets the name of project id. An empty name reverts to the
+// directory's base name.
+func Rename(db *sql.DB, id, name string) error {
+	var nameVal interface{}
+	if name != "" {
+		nameVal = name
+	}
+	if _, err := db.Exec(`UPDATE projects SET name = ? WHERE id = ?`, nameVal, id); err != nil {
+		return fmt.Errorf("rename project: %w", err)
 	}
-	return s[:i] + new + s[i+len(old):]
+	return nil
 }
 
-func indexOf(s, sub string) int {
-	for i := 0; i <= len(s)-len(sub); i++ {
-		if s[i:i+len(sub)] == sub {
-			return i
+// Merge moves every session and prompt of project from into project into,
+// then deletes from. into keeps its own name, directory and origin, taking
+// from's origin only if it has none. Otherwise from's origin is kept as a
+// further origin of into, so clones of it keep matching into.
+func Merge(db *sql.DB, into, from string) error {
+	if into == from {
+		return fmt.Errorf("cannot merge a project into itself")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		`UPDATE projects SET
+		     git_origin = COALESCE(git_origin, (SELECT git_origin FROM projects WHERE id = ?2)),
+		     origin_key = COALESCE(origin_key, (SELECT origin_key FROM projects WHERE id = ?2))
+		 WHERE id = ?1`,
+		`UPDATE project_origins SET project_id = ?1 WHERE project_id = ?2`,
+		`INSERT OR REPLACE INTO project_origins (origin_key, project_id)
+		 SELECT origin_key, ?1 FROM projects WHERE id = ?2 AND origin_key IS NOT NULL`,
+		`DELETE FROM project_origins
+		 WHERE project_id = ?1 AND origin_key = (SELECT origin_key FROM projects WHERE id = ?1)`,
+		`UPDATE sessions SET project_id = ?1 WHERE project_id = ?2`,
+		`UPDATE prompts SET project_id = ?1 WHERE project_id = ?2`,
+		`DELETE FROM projects WHERE id = ?2`,
+	} {
+		if _, err := tx.Exec(stmt, into, from); err != nil {
+			return fmt.Errorf("merge project: %w", err)
 		}
 	}
-	return -1
+	return tx.Commit()
+}
+
+// Forget deletes project id with all of its prompts and sessions, and
+// everything recorded for them. A session that also holds prompts of
+// another project (the agent moved between directories) is kept and moved
+// to that project.
+func Forget(db *sql.DB, id string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	const projectPrompts = `SELECT id FROM prompts WHERE project_id = ?1`
+	const projectSessions = `SELECT id FROM sessions WHERE project_id = ?1`
+	for _, stmt := range []string{
+		`DELETE FROM tool_calls     WHERE prompt_id IN (` + projectPrompts + `)`,
+		`DELETE FROM prompt_waits   WHERE prompt_id IN (` + projectPrompts + `)`,
+		`DELETE FROM subagents      WHERE prompt_id IN (` + projectPrompts + `)`,
+		`DELETE FROM prompt_files   WHERE prompt_id IN (` + projectPrompts + `)`,
+		`DELETE FROM prompt_commits WHERE prompt_id IN (` + projectPrompts + `)`,
+		`DELETE FROM prompts WHERE project_id = ?1`,
+		`UPDATE sessions SET project_id = (
+		     SELECT project_id FROM prompts WHERE prompts.session_id = sessions.id LIMIT 1)
+		 WHERE project_id = ?1 AND EXISTS (SELECT 1 FROM prompts WHERE prompts.session_id = sessions.id)`,
+		`DELETE FROM tool_calls   WHERE session_id IN (` + projectSessions + `)`,
+		`DELETE FROM prompt_waits WHERE session_id IN (` + projectSessions + `)`,
+		`DELETE FROM subagents    WHERE session_id IN (` + projectSessions + `)`,
+		`DELETE FROM sessions WHERE project_id = ?1`,
+		`DELETE FROM project_origins WHERE project_id = ?1`,
+		`DELETE FROM projects WHERE id = ?1`,
+	} {
+		if _, err := tx.Exec(stmt, id); err != nil {
+			return fmt.Errorf("forget project: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ShortName returns a human-readable name for the project: the name it was
+// given with Rename, or else the base name of its directory.
+func (p *Project) ShortName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return filepath.Base(p.Directory)
+}
+
+// DisplayOrigin returns the git origin in its canonical form, e.g.
+// "github.com/user/repo" (see gitx.OriginKey).
+func (p *Project) DisplayOrigin() string {
+	return gitx.OriginKey(p.GitOrigin)
+}
+
+// Org returns the organisation the project's git origin belongs to, as
+// "host/owner" (e.g. "github.com/user"), or "" if it has no remote origin.
+func (p *Project) Org() string {
+	parts := strings.Split(p.DisplayOrigin(), "/")
+	// A remote origin is at least host/owner/repo; local paths start with
+	// "/" or "." or have fewer parts.
+	if len(parts) < 3 || parts[0] == "" || strings.HasPrefix(parts[0], ".") {
+		return ""
+	}
+	return parts[0] + "/" + parts[1]
 }
 
 // cwdExists is used in tests; exported for test packages.
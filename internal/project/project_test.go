@@ -1,6 +1,8 @@
 package project_test
 
 import (
+	"os"
+	"os/exec"
 	"path/filepath"
 	"testing"
 
@@ -76,3 +78,304 @@ func TestFind_NotFound(t *testing.T) {
 		t.Error("expected nil for unknown project")
 	}
 }
+
+func TestUpsert_Worktrees(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test",
+			"GIT_AUTHOR_EMAIL=test@test.com",
+			"GIT_COMMITTER_NAME=Test",
+			"GIT_COMMITTER_EMAIL=test@test.com",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("commit", "-q", "--allow-empty", "-m", "init")
+	wt := filepath.Join(t.TempDir(), "feature")
+	git("worktree", "add", "-q", "-b", "feature", wt)
+
+	main, err := project.Upsert(database, dir)
+	if err != nil {
+		t.Fatalf("Upsert main: %v", err)
+	}
+	linked, err := project.Upsert(database, wt)
+	if err != nil {
+		t.Fatalf("Upsert worktree: %v", err)
+	}
+	if linked.ID != main.ID {
+		t.Errorf("worktree got its own project %q, want %q", linked.ID, main.ID)
+	}
+
+	// Alternating between worktrees must not move the project.
+	p, err := project.Find(database, wt)
+	if err != nil || p == nil {
+		t.Fatalf("Find worktree: %v, %v", p, err)
+	}
+	if p.Directory != main.Directory {
+		t.Errorf("project directory changed to %q, want %q", p.Directory, main.Directory)
+	}
+}
+
+func TestUpsert_OriginKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	clone := func(origin string) string {
+		t.Helper()
+		dir := t.TempDir()
+		for _, args := range [][]string{{"init", "-q"}, {"remote", "add", "origin", origin}} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v\n%s", args, err, out)
+			}
+		}
+		return dir
+	}
+
+	ssh, err := project.Upsert(database, clone("git@github.com:Org/Repo.git"))
+	if err != nil {
+		t.Fatalf("Upsert ssh clone: %v", err)
+	}
+	httpsDir := clone("https://github.com/org/repo")
+	p, err := project.Find(database, httpsDir)
+	if err != nil || p == nil {
+		t.Fatalf("Find https clone: %v, %v", p, err)
+	}
+	if p.ID != ssh.ID {
+		t.Errorf("https clone found project %q, want %q", p.ID, ssh.ID)
+	}
+	https, err := project.Upsert(database, httpsDir)
+	if err != nil {
+		t.Fatalf("Upsert https clone: %v", err)
+	}
+	if https.ID != ssh.ID {
+		t.Errorf("https clone got its own project %q, want %q", https.ID, ssh.ID)
+	}
+	// Clones used side by side don't move the project.
+	if https.Directory != ssh.Directory {
+		t.Errorf("project moved to %q, want it kept at %q", https.Directory, ssh.Directory)
+	}
+	// A clone that has been deleted hands the project to the next one.
+	if err := os.RemoveAll(ssh.Directory); err != nil {
+		t.Fatal(err)
+	}
+	moved, err := project.Upsert(database, httpsDir)
+	if err != nil {
+		t.Fatalf("Upsert after removing ssh clone: %v", err)
+	}
+	if want, _ := project.Resolve(httpsDir); moved.ID != ssh.ID || moved.Directory != want {
+		t.Errorf("after removing ssh clone: got project %q at %q, want %q at %q", moved.ID, moved.Directory, ssh.ID, want)
+	}
+	if got := https.DisplayOrigin(); got != "github.com/org/repo" {
+		t.Errorf("DisplayOrigin: got %q", got)
+	}
+
+	other, err := project.Upsert(database, clone("git@github.com:org/other.git"))
+	if err != nil {
+		t.Fatalf("Upsert other clone: %v", err)
+	}
+	if other.ID == ssh.ID {
+		t.Error("different repos share a project")
+	}
+}
+
+func TestSubproject(t *testing.T) {
+	root := t.TempDir()
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = root
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	for _, dir := range []string{"services/billing/internal", "services/auth/cmd", "web/src", "docs"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"go.mod", "services/auth/go.mod", "web/package.json"} {
+		if err := os.WriteFile(filepath.Join(root, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules := project.SubprojectRules{
+		Prefixes: map[string]string{
+			"services":          "services",
+			"services/billing/": "billing",
+		},
+		Detect: []string{"go.mod", "package.json"},
+	}
+	for _, tc := range []struct {
+		cwd, subdir, want string
+	}{
+		{"", "", ""},
+		{"services/billing/internal", "services/billing/internal", "billing"}, // longest prefix
+		{"services/auth/cmd", "services/auth/cmd", "services"},                // prefixes beat detection
+		{"web/src", "web/src", "web"},                                         // detected package.json
+		{"docs", "docs", ""},                                                  // root go.mod doesn't count
+	} {
+		cwd := filepath.Join(root, tc.cwd)
+		if got := project.Subdir(cwd); got != tc.subdir {
+			t.Errorf("Subdir(%q): got %q, want %q", tc.cwd, got, tc.subdir)
+		}
+		if got := project.Subproject(cwd, rules); got != tc.want {
+			t.Errorf("Subproject(%q): got %q, want %q", tc.cwd, got, tc.want)
+		}
+	}
+
+	rules.Prefixes = nil
+	if got := project.Subproject(filepath.Join(root, "services/auth/cmd"), rules); got != "services/auth" {
+		t.Errorf("Subproject with detection only: got %q, want services/auth", got)
+	}
+}
+
+func TestRenameMergeForget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	a, err := project.Upsert(database, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := project.Upsert(database, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`INSERT INTO sessions (id, project_id) VALUES ('sa', '` + a.ID + `'), ('sb', '` + b.ID + `')`,
+		`INSERT INTO prompts (id, session_id, project_id, submitted_at) VALUES
+		     ('pa', 'sa', '` + a.ID + `', '2026-01-01 10:00:00'),
+		     ('pb', 'sb', '` + b.ID + `', '2026-01-01 11:00:00')`,
+		`INSERT INTO tool_calls (id, prompt_id, session_id, tool_name, started_at) VALUES
+		     ('ta', 'pa', 'sa', 'Bash', '2026-01-01 10:00:01')`,
+		`INSERT INTO prompt_files (prompt_id, path, lines_added, lines_removed) VALUES ('pa', 'main.go', 1, 0)`,
+	} {
+		if _, err := database.Exec(stmt); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	if err := project.Rename(database, a.ID, "api"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	for _, ref := range []string{"api", a.ID, a.ID[:8], a.Directory} {
+		p, err := project.Lookup(database, ref)
+		if err != nil || p == nil || p.ID != a.ID {
+			t.Errorf("Lookup(%q): got %v, %v; want project %s", ref, p, err, a.ID)
+		}
+	}
+	if p, err := project.Lookup(database, "nope"); err != nil || p != nil {
+		t.Errorf("Lookup(nope): got %v, %v", p, err)
+	}
+
+	if err := project.Merge(database, a.ID, b.ID); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if p, _ := project.FindByID(database, b.ID); p != nil {
+		t.Error("merged project still exists")
+	}
+	var n int
+	if err := database.QueryRow(`SELECT COUNT(*) FROM prompts WHERE project_id = ?`, a.ID).Scan(&n); err != nil || n != 2 {
+		t.Errorf("prompts after merge: got %d, %v; want 2", n, err)
+	}
+
+	if err := project.Forget(database, a.ID); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	for _, table := range []string{"projects", "sessions", "prompts", "tool_calls", "prompt_files"} {
+		if err := database.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil || n != 0 {
+			t.Errorf("%s after forget: got %d rows, %v", table, n, err)
+		}
+	}
+}
+
+func TestOrg(t *testing.T) {
+	for _, tc := range []struct{ origin, want string }{
+		{"", ""},
+		{"git@github.com:Acme/api.git", "github.com/acme"},
+		{"https://gitlab.com/group/sub/tool", "gitlab.com/group"},
+		{"/srv/git/repo.git", ""},
+		{"../repo", ""},
+	} {
+		p := &project.Project{GitOrigin: tc.origin}
+		if got := p.Org(); got != tc.want {
+			t.Errorf("Org(%q): got %q, want %q", tc.origin, got, tc.want)
+		}
+	}
+}
+
+func TestMerge_KeepsDuplicateOrigin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	database, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer database.Close()
+
+	clone := func(origin string) string {
+		t.Helper()
+		dir := t.TempDir()
+		for _, args := range [][]string{{"init", "-q"}, {"remote", "add", "origin", origin}} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = dir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v\n%s", args, err, out)
+			}
+		}
+		return dir
+	}
+
+	// A repo that moved from one host to another.
+	oldDir := clone("git@gitlab.com:org/repo.git")
+	into, err := project.Upsert(database, clone("git@github.com:org/repo.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dup, err := project.Upsert(database, oldDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Merge(database, into.ID, dup.ID); err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+
+	p, err := project.Upsert(database, oldDir)
+	if err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	if p.ID != into.ID {
+		t.Errorf("duplicate's clone got project %q, want %q", p.ID, into.ID)
+	}
+	if got := p.DisplayOrigin(); got != "github.com/org/repo" {
+		t.Errorf("DisplayOrigin: got %q, want the merged project's own", got)
+	}
+
+	if err := project.Forget(database, into.ID); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+	var n int
+	if err := database.QueryRow(`SELECT COUNT(*) FROM project_origins`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("project_origins after forget: got %d rows, %v", n, err)
+	}
+}
@@ -0,0 +1,137 @@
+// Package timerange parses the time expressions accepted by --since and
+// --until: absolute dates and times, and relative expressions such as "7d"
+// or "last-week".
+package timerange
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Range is the span of time [Start, End) an expression denotes. --since
+// takes its Start and --until its End, so "--since last-week --until
+// last-week" covers the whole of last week. Expressions for a moment, such
+// as "7d" or "2024-02-15 10:30", have Start equal to End.
+type Range struct {
+	Start time.Time
+	End   time.Time
+}
+
+// absoluteLayouts are the absolute forms accepted, with the unit of time
+// each denotes (zero for a moment).
+var absoluteLayouts = []struct {
+	layout string
+	unit   string
+}{
+	{"2006-01-02", "day"},
+	{"2006-01", "month"},
+	{"2006-01-02 15:04", ""},
+	{"2006-01-02T15:04", ""},
+	{"2006-01-02 15:04:05", ""},
+	{"2006-01-02T15:04:05", ""},
+	{time.RFC3339, ""},
+}
+
+// Parse returns the range denoted by s, relative to now. Calendar
+// boundaries (days, weeks starting on Monday, months, years) are taken in
+// now's location, as are absolute times without a zone. Accepted forms:
+//
+//	now
+//	today, yesterday
+//	this-week, last-week, this-month, last-month, this-year, last-year
+//	<n>h, <n>d, <n>w     the moment n hours, days or weeks ago
+//	2024-02-15           that day
+//	2024-02              that month
+//	2024-02-15 10:30     that moment (also with seconds, a "T", or RFC 3339)
+func Parse(s string, now time.Time) (Range, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	today := startOfDay(now)
+
+	switch s {
+	case "":
+		return Range{}, fmt.Errorf("empty time expression")
+	case "now":
+		return Range{now, now}, nil
+	case "today":
+		return Range{today, today.AddDate(0, 0, 1)}, nil
+	case "yesterday":
+		return Range{today.AddDate(0, 0, -1), today}, nil
+	case "this-week":
+		start := startOfWeek(today)
+		return Range{start, start.AddDate(0, 0, 7)}, nil
+	case "last-week":
+		end := startOfWeek(today)
+		return Range{end.AddDate(0, 0, -7), end}, nil
+	case "this-month":
+		start := startOfMonth(today)
+		return Range{start, start.AddDate(0, 1, 0)}, nil
+	case "last-month":
+		end := startOfMonth(today)
+		return Range{end.AddDate(0, -1, 0), end}, nil
+	case "this-year":
+		start := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, today.Location())
+		return Range{start, start.AddDate(1, 0, 0)}, nil
+	case "last-year":
+		end := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, today.Location())
+		return Range{end.AddDate(-1, 0, 0), end}, nil
+	}
+
+	if t, ok := parseAgo(s, now); ok {
+		return Range{t, t}, nil
+	}
+
+	for _, l := range absoluteLayouts {
+		t, err := time.ParseInLocation(l.layout, strings.ToUpper(s), now.Location())
+		if err != nil {
+			continue
+		}
+		switch l.unit {
+		case "day":
+			return Range{t, t.AddDate(0, 0, 1)}, nil
+		case "month":
+			return Range{t, t.AddDate(0, 1, 0)}, nil
+		}
+		return Range{t, t}, nil
+	}
+
+	return Range{}, fmt.Errorf("unrecognised time %q (want e.g. 2024-02-15, 7d, today, last-week, this-month)", s)
+}
+
+// parseAgo parses "<n>h", "<n>d" or "<n>w" as the moment that long before
+// now. Days and weeks are calendar days, so they keep the time of day across
+// daylight saving changes.
+func parseAgo(s string, now time.Time) (time.Time, bool) {
+	if len(s) < 2 {
+		return time.Time{}, false
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 0 {
+		return time.Time{}, false
+	}
+	switch s[len(s)-1] {
+	case 'h':
+		return now.Add(-time.Duration(n) * time.Hour), true
+	case 'd':
+		return now.AddDate(0, 0, -n), true
+	case 'w':
+		return now.AddDate(0, 0, -7*n), true
+	}
+	return time.Time{}, false
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// startOfWeek returns the Monday of the week containing day.
+func startOfWeek(day time.Time) time.Time {
+	// Weekday counts from Sunday = 0; shift so Monday is 0.
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func startOfMonth(day time.Time) time.Time {
+	return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+}
@@ -0,0 +1,70 @@
+package timerange_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dansimau/agentstats/internal/timerange"
+)
+
+func TestParse(t *testing.T) {
+	loc := time.FixedZone("test", 2*60*60)
+	// A Wednesday.
+	now := time.Date(2024, 2, 14, 15, 30, 0, 0, loc)
+	at := func(y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, loc)
+	}
+
+	for _, tc := range []struct {
+		expr       string
+		start, end time.Time
+	}{
+		{"now", now, now},
+		{"today", at(2024, 2, 14, 0, 0), at(2024, 2, 15, 0, 0)},
+		{" Today ", at(2024, 2, 14, 0, 0), at(2024, 2, 15, 0, 0)},
+		{"yesterday", at(2024, 2, 13, 0, 0), at(2024, 2, 14, 0, 0)},
+		{"this-week", at(2024, 2, 12, 0, 0), at(2024, 2, 19, 0, 0)},
+		{"last-week", at(2024, 2, 5, 0, 0), at(2024, 2, 12, 0, 0)},
+		{"this-month", at(2024, 2, 1, 0, 0), at(2024, 3, 1, 0, 0)},
+		{"last-month", at(2024, 1, 1, 0, 0), at(2024, 2, 1, 0, 0)},
+		{"this-year", at(2024, 1, 1, 0, 0), at(2025, 1, 1, 0, 0)},
+		{"last-year", at(2023, 1, 1, 0, 0), at(2024, 1, 1, 0, 0)},
+		{"12h", at(2024, 2, 14, 3, 30), at(2024, 2, 14, 3, 30)},
+		{"7d", at(2024, 2, 7, 15, 30), at(2024, 2, 7, 15, 30)},
+		{"2w", at(2024, 1, 31, 15, 30), at(2024, 1, 31, 15, 30)},
+		{"2024-01-31", at(2024, 1, 31, 0, 0), at(2024, 2, 1, 0, 0)},
+		{"2023-12", at(2023, 12, 1, 0, 0), at(2024, 1, 1, 0, 0)},
+		{"2024-02-10 09:15", at(2024, 2, 10, 9, 15), at(2024, 2, 10, 9, 15)},
+		{"2024-02-10T09:15", at(2024, 2, 10, 9, 15), at(2024, 2, 10, 9, 15)},
+		{"2024-02-10T07:15:00Z", at(2024, 2, 10, 9, 15), at(2024, 2, 10, 9, 15)},
+	} {
+		r, err := timerange.Parse(tc.expr, now)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tc.expr, err)
+			continue
+		}
+		if !r.Start.Equal(tc.start) || !r.End.Equal(tc.end) {
+			t.Errorf("Parse(%q): got [%v, %v), want [%v, %v)", tc.expr, r.Start, r.End, tc.start, tc.end)
+		}
+	}
+}
+
+func TestParse_WeekStartsMonday(t *testing.T) {
+	// On a Sunday, this week began six days ago.
+	sunday := time.Date(2024, 2, 18, 12, 0, 0, 0, time.UTC)
+	r, err := timerange.Parse("this-week", sunday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 2, 12, 0, 0, 0, 0, time.UTC); !r.Start.Equal(want) {
+		t.Errorf("this-week on Sunday starts %v, want %v", r.Start, want)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "soon", "7", "d", "-3d", "7y", "2024-13-01", "last-fortnight"} {
+		if _, err := timerange.Parse(expr, time.Now()); err == nil {
+			t.Errorf("Parse(%q): expected an error", expr)
+		}
+	}
+}
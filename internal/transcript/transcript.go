@@ -0,0 +1,218 @@
+// Package transcript reads Claude Code session transcripts: the JSONL files
+// referenced by transcript_path in hook payloads and stored under
+// ~/.claude/projects.
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Usage is the token usage reported by the API for one or more assistant
+// messages.
+type Usage struct {
+	InputTokens      int64
+	OutputTokens     int64
+	CacheReadTokens  int64
+	CacheWriteTokens int64
+}
+
+// Add accumulates o into u.
+func (u *Usage) Add(o Usage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CacheReadTokens += o.CacheReadTokens
+	u.CacheWriteTokens += o.CacheWriteTokens
+}
+
+// Total returns the sum of all token counts.
+func (u Usage) Total() int64 {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheWriteTokens
+}
+
+// Turn is one user prompt and the assistant work that followed it, up to the
+// next prompt.
+type Turn struct {
+	UUID        string // uuid of the prompt entry
+	SessionID   string
+	Cwd         string
+	Prompt      string
+	SubmittedAt time.Time
+	CompletedAt time.Time // last assistant entry; zero if there was no response
+	Model       string    // model of the last assistant message
+	Usage       Usage
+}
+
+// entry is a single line of a transcript. Only the fields we use are decoded.
+type entry struct {
+	Type             string    `json:"type"`
+	UUID             string    `json:"uuid"`
+	SessionID        string    `json:"sessionId"`
+	Cwd              string    `json:"cwd"`
+	Timestamp        time.Time `json:"timestamp"`
+	IsMeta           bool      `json:"isMeta"`
+	IsSidechain      bool      `json:"isSidechain"`
+	IsCompactSummary bool      `json:"isCompactSummary"`
+	Message          struct {
+		ID      string          `json:"id"`
+		Role    string          `json:"role"`
+		Model   string          `json:"model"`
+		Content json.RawMessage `json:"content"`
+		Usage   *struct {
+			InputTokens              int64 `json:"input_tokens"`
+			OutputTokens             int64 `json:"output_tokens"`
+			CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+			CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+}
+
+// Read parses the transcript at path. See Parse.
+func Read(path string) ([]Turn, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse splits a transcript into turns. Assistant messages are written once
+// per content block with the same message ID, so usage is counted once per
+// message. Sidechain (subagent) usage is attributed to the enclosing turn.
+// Lines that fail to decode are skipped.
+func Parse(r io.Reader) ([]Turn, error) {
+	var turns []Turn
+	var cur *Turn
+	// Usage per message ID for the current turn; the last entry wins.
+	var usage map[string]Usage
+	var order []string
+
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		for _, id := range order {
+			cur.Usage.Add(usage[id])
+		}
+		turns = append(turns, *cur)
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		var e entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+
+		switch e.Type {
+		case "user":
+			text, ok := promptText(&e)
+			if !ok {
+				continue
+			}
+			flush()
+			cur = &Turn{
+				UUID:        e.UUID,
+				SessionID:   e.SessionID,
+				Cwd:         e.Cwd,
+				Prompt:      text,
+				SubmittedAt: e.Timestamp,
+			}
+			usage = map[string]Usage{}
+			order = nil
+
+		case "assistant":
+			if cur == nil || e.Message.Model == "<synthetic>" {
+				continue
+			}
+			if e.Timestamp.After(cur.CompletedAt) {
+				cur.CompletedAt = e.Timestamp
+			}
+			if e.Message.Model != "" && !e.IsSidechain {
+				cur.Model = e.Message.Model
+			}
+			if u := e.Message.Usage; u != nil {
+				id := e.Message.ID
+				if id == "" {
+					id = e.UUID
+				}
+				if _, seen := usage[id]; !seen {
+					order = append(order, id)
+				}
+				usage[id] = Usage{
+					InputTokens:      u.InputTokens,
+					OutputTokens:     u.OutputTokens,
+					CacheReadTokens:  u.CacheReadInputTokens,
+					CacheWriteTokens: u.CacheCreationInputTokens,
+				}
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read transcript: %w", err)
+	}
+	flush()
+	return turns, nil
+}
+
+// LastTurn returns the last turn whose prompt matches prompt, falling back to
+// the last turn overall. It returns nil if there are no turns.
+func LastTurn(turns []Turn, prompt string) *Turn {
+	if len(turns) == 0 {
+		return nil
+	}
+	prompt = strings.TrimSpace(prompt)
+	for i := len(turns) - 1; i >= 0; i-- {
+		if prompt != "" && turns[i].Prompt == prompt {
+			return &turns[i]
+		}
+	}
+	return &turns[len(turns)-1]
+}
+
+// promptText returns the text of a user entry if it is a prompt typed by the
+// user, as opposed to a tool result, meta message, or local command output.
+func promptText(e *entry) (string, bool) {
+	if e.IsMeta || e.IsSidechain || e.IsCompactSummary || e.Message.Role != "user" {
+		return "", false
+	}
+
+	var text string
+	var s string
+	if err := json.Unmarshal(e.Message.Content, &s); err == nil {
+		text = s
+	} else {
+		var blocks []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(e.Message.Content, &blocks); err != nil {
+			return "", false
+		}
+		var parts []string
+		for _, b := range blocks {
+			switch b.Type {
+			case "tool_result":
+				return "", false
+			case "text":
+				parts = append(parts, b.Text)
+			}
+		}
+		text = strings.Join(parts, "\n")
+	}
+
+	text = strings.TrimSpace(text)
+	for _, prefix := range []string{"<local-command-", "[Request interrupted"} {
+		if strings.HasPrefix(text, prefix) {
+			return "", false
+		}
+	}
+	return text, text != ""
+}
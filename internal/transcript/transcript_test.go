@@ -0,0 +1,81 @@
+package transcript_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dansimau/agentstats/internal/transcript"
+)
+
+const sample = `{"type":"summary","summary":"Earlier work","leafUuid":"x"}
+{"type":"user","uuid":"u1","sessionId":"s1","cwd":"/repo","timestamp":"2025-06-01T10:00:00.000Z","message":{"role":"user","content":"Add a README"}}
+{"type":"assistant","uuid":"a1","sessionId":"s1","timestamp":"2025-06-01T10:00:05.000Z","message":{"id":"msg_1","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"text","text":"Sure"}],"usage":{"input_tokens":10,"output_tokens":1,"cache_read_input_tokens":100,"cache_creation_input_tokens":50}}}
+{"type":"assistant","uuid":"a2","sessionId":"s1","timestamp":"2025-06-01T10:00:06.000Z","message":{"id":"msg_1","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"tool_use","id":"toolu_1"}],"usage":{"input_tokens":10,"output_tokens":20,"cache_read_input_tokens":100,"cache_creation_input_tokens":50}}}
+{"type":"user","uuid":"u2","sessionId":"s1","timestamp":"2025-06-01T10:00:07.000Z","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"ok"}]}}
+{"type":"assistant","uuid":"a3","sessionId":"s1","isSidechain":true,"timestamp":"2025-06-01T10:00:08.000Z","message":{"id":"msg_sub","role":"assistant","model":"claude-3-5-haiku-20241022","usage":{"input_tokens":5,"output_tokens":5}}}
+{"type":"assistant","uuid":"a4","sessionId":"s1","timestamp":"2025-06-01T10:00:30.000Z","message":{"id":"msg_2","role":"assistant","model":"claude-sonnet-4-20250514","usage":{"input_tokens":1,"output_tokens":2,"cache_read_input_tokens":3,"cache_creation_input_tokens":4}}}
+not json
+{"type":"user","uuid":"u3","sessionId":"s1","isMeta":true,"timestamp":"2025-06-01T10:01:00.000Z","message":{"role":"user","content":"<local-command-caveat>ignore</local-command-caveat>"}}
+{"type":"user","uuid":"u4","sessionId":"s1","timestamp":"2025-06-01T10:02:00.000Z","message":{"role":"user","content":[{"type":"text","text":"[Request interrupted by user]"}]}}
+{"type":"user","uuid":"u5","sessionId":"s1","timestamp":"2025-06-01T10:05:00.000Z","message":{"role":"user","content":[{"type":"text","text":"Now add tests"}]}}
+{"type":"assistant","uuid":"a5","sessionId":"s1","timestamp":"2025-06-01T10:05:01.000Z","message":{"id":"msg_3","role":"assistant","model":"<synthetic>","usage":{"input_tokens":0,"output_tokens":0}}}
+`
+
+func TestParse(t *testing.T) {
+	turns, err := transcript.Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(turns) != 2 {
+		t.Fatalf("expected 2 turns, got %d", len(turns))
+	}
+
+	first := turns[0]
+	if first.UUID != "u1" || first.Prompt != "Add a README" || first.Cwd != "/repo" {
+		t.Errorf("first turn: got %+v", first)
+	}
+	if first.Model != "claude-sonnet-4-20250514" {
+		t.Errorf("Model: got %q", first.Model)
+	}
+	if got := first.CompletedAt.Sub(first.SubmittedAt).Seconds(); got != 30 {
+		t.Errorf("duration: got %vs, want 30s", got)
+	}
+	want := transcript.Usage{
+		InputTokens:      10 + 5 + 1,
+		OutputTokens:     20 + 5 + 2,
+		CacheReadTokens:  100 + 3,
+		CacheWriteTokens: 50 + 4,
+	}
+	if first.Usage != want {
+		t.Errorf("Usage: got %+v, want %+v", first.Usage, want)
+	}
+
+	second := turns[1]
+	if second.Prompt != "Now add tests" {
+		t.Errorf("second prompt: got %q", second.Prompt)
+	}
+	if !second.CompletedAt.IsZero() || second.Usage.Total() != 0 {
+		t.Errorf("synthetic message should be ignored, got %+v", second)
+	}
+}
+
+func TestRead_Missing(t *testing.T) {
+	if _, err := transcript.Read(filepath.Join(t.TempDir(), "nope.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error, got %v", err)
+	}
+}
+
+func TestLastTurn(t *testing.T) {
+	if transcript.LastTurn(nil, "x") != nil {
+		t.Error("expected nil for no turns")
+	}
+	turns := []transcript.Turn{{Prompt: "a"}, {Prompt: "b"}, {Prompt: "c"}}
+	if got := transcript.LastTurn(turns, "b"); got.Prompt != "b" {
+		t.Errorf("matching prompt: got %q", got.Prompt)
+	}
+	if got := transcript.LastTurn(turns, "unknown"); got.Prompt != "c" {
+		t.Errorf("fallback: got %q", got.Prompt)
+	}
+}